@@ -1,9 +1,10 @@
 #!/bin/bash
 
-# compare the dot output files
+# compare the DAGs captured from the nodes
 N=$1
 dir=./opera_images
 FILE=DAG-EPOCH-1
+EXEC=./bin/dot-tool
 
 diffdir=${dir}/diff
 mkdir -p ${diffdir}
@@ -12,9 +13,8 @@ for i in $(seq $((N-1)))
 do
 	echo $i
 	f=${dir}/$i/${FILE}
-	fo=${dir}/$((i+1))/${FILE}	
-	difffile="${diffdir}/$i-$((i+1))".diff
-	
-	diff ${f}.dot ${fo}.dot > ${difffile}
-done
+	fo=${dir}/$((i+1))/${FILE}
+	difffile="${diffdir}/$i-$((i+1))"
 
+	${EXEC} diff -out ${difffile}.dot ${f}.dot ${fo}.dot > ${difffile}.diff
+done
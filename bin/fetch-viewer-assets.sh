@@ -0,0 +1,17 @@
+#!/bin/bash
+
+# This script downloads the scripts of the live viewer into cmd/dot-tool/assets,
+# they are embedded into the binary on the next build, so the viewer works offline.
+# Without them the viewer redirects the browser to unpkg.com.
+#
+# Example usage:
+#
+# ./bin/fetch-viewer-assets.sh && go build ./cmd/dot-tool
+set -e
+
+cd "$(dirname "$0")/../cmd/dot-tool/assets"
+
+# keep the versions in sync with viewerAssets in cmd/dot-tool/serve.go
+curl -sSfL -o d3.min.js https://unpkg.com/d3@7.8.5/dist/d3.min.js
+curl -sSfL -o graphviz.umd.js https://unpkg.com/@hpcc-js/wasm@2.13.0/dist/graphviz.umd.js
+curl -sSfL -o d3-graphviz.min.js https://unpkg.com/d3-graphviz@5.1.0/build/d3-graphviz.min.js
@@ -1,7 +1,7 @@
 #!/bin/bash
 
-# This script will start $N$ instances of dot-tools
-# each of the dot-tool connects to a running node
+# This script will start a dot-tool capturing $N$ running nodes at once
+# every node gets its own image folder, the union DAG is written to the root folder
 #
 # Example usage:
 #
@@ -10,6 +10,9 @@
 #
 # Start capturing 5 nodes using dot-tool in epoch mode
 # ./bin/start.sh 5 epoch
+#
+# Settings of the config file are used if CONFIG is set, e.g.
+# CONFIG=./bin/dot-tool.yaml ./bin/start.sh 5 epoch
 set -e
 
 # number of nodes N
@@ -33,14 +36,13 @@ mkdir -p ${DOT_DIR}
 
 
 echo -e "\nStarting dot-tool:\n"
+endpoints=""
 for i in $(seq $N)
 do
     port=$((PORT + i))
-    d=${DOT_DIR}/${i}
-    mkdir -p $d
-
-    echo " starting at port: ${port}, image folder: ${d}"
-    ${EXEC} -mode ${mode} -host localhost \
-	-port ${port} -out ${d} >${d}.log 2>${d}.err &
+    endpoints="${endpoints:+${endpoints},}${IP}:${port}"
+    echo " node ${i} at port: ${port}, image folder: ${DOT_DIR}/${i}"
 done
 
+${EXEC} ${CONFIG:+-config ${CONFIG}} -mode ${mode} -endpoints ${endpoints} \
+	-out ${DOT_DIR} >${DOT_DIR}.log 2>${DOT_DIR}.err &
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// Colors of the decisions, cycled in the order of the blocks
+var decisionColors = []dot.Color{
+	dot.MustParseColor("blue"),
+	dot.MustParseColor("darkgreen"),
+	dot.MustParseColor("purple"),
+	dot.MustParseColor("darkorange"),
+	dot.MustParseColor("brown"),
+	dot.MustParseColor("deeppink"),
+}
+
+// atroposTracker asks the node for the Atropos of every block.
+// Opera uses the Atropos event ID as the block hash.
+// The known blocks are a contiguous range down to the last block of the
+// previous epoch, the blocks of the older epochs are dropped.
+type atroposTracker struct {
+	client eventSource
+	blocks map[idx.Block]hash.Event
+}
+
+func newAtroposTracker(client eventSource) *atroposTracker {
+	return &atroposTracker{
+		client: client,
+		blocks: make(map[idx.Block]hash.Event),
+	}
+}
+
+type rpcBlock struct {
+	Number hexutil.Uint64 `json:"number"`
+	Hash   common.Hash    `json:"hash"`
+}
+
+// Atropoi returns the Atropos events of the epoch sorted by block
+func (t *atroposTracker) Atropoi(ctx context.Context, epoch idx.Epoch) ([]types.BlockAtropos, error) {
+	var latest hexutil.Uint64
+	if err := t.client.CallContext(ctx, &latest, "eth_blockNumber"); err != nil {
+		return nil, err
+	}
+
+	// walk back until a known block or a block of a previous epoch,
+	// the walked blocks are kept only if the walk succeeds, so the known range has no gaps
+	walked := make(map[idx.Block]hash.Event)
+	for n := idx.Block(latest); n > 0; n-- {
+		if _, ok := t.blocks[n]; ok {
+			break
+		}
+		var b rpcBlock
+		err := t.client.CallContext(ctx, &b, "eth_getBlockByNumber", hexutil.EncodeUint64(uint64(n)), false)
+		if err != nil {
+			return nil, err
+		}
+		atropos := hash.Event(b.Hash)
+		walked[n] = atropos
+		if atropos.Epoch() < epoch {
+			break
+		}
+	}
+	for n, atropos := range walked {
+		t.blocks[n] = atropos
+	}
+	t.prune(epoch)
+
+	res := make([]types.BlockAtropos, 0)
+	for n, atropos := range t.blocks {
+		if atropos.Epoch() == epoch {
+			res = append(res, types.BlockAtropos{Block: n, Atropos: atropos})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Block < res[j].Block
+	})
+	return res, nil
+}
+
+// prune drops the blocks of the epochs before the given one, except the last of them which stops the next walk
+func (t *atroposTracker) prune(epoch idx.Epoch) {
+	var last idx.Block
+	for n, atropos := range t.blocks {
+		if atropos.Epoch() < epoch && n > last {
+			last = n
+		}
+	}
+	for n, atropos := range t.blocks {
+		if atropos.Epoch() < epoch && n != last {
+			delete(t.blocks, n)
+		}
+	}
+}
+
+// markDecisions styles Atropos and confirmed events and returns the legend subgraph
+func markDecisions(decisions []*types.Decision, inGraph map[string]*dot.Node) *dot.SubGraph {
+	legend := dot.NewSubgraph("cluster_legend")
+	checkAttrs("legend", legend.SetLabel("Atropos"), legend.SetStyle(dot.StyleRounded))
+
+	for i, d := range decisions {
+		color := decisionColors[i%len(decisionColors)]
+		for _, p := range d.Confirmed {
+			if n, ok := inGraph[p.NodeName]; ok {
+				n.SetFontColor(color)
+			}
+		}
+		if n, ok := inGraph[d.Atropos.NodeName]; ok {
+			n.SetShape(dot.ShapeDoubleOctagon)
+		}
+
+		ln := dot.NewNode("legend-" + strconv.FormatUint(uint64(d.Block), 10))
+		ln.SetShape(dot.ShapeNote)
+		ln.SetFontColor(color)
+		checkAttrs("legend", ln.SetLabel(fmt.Sprintf("frame %d: block %d\natropos %s\nconfirms %d events",
+			d.Frame, d.Block, d.Atropos.ID().String(), len(d.Confirmed))))
+		legend.AddNode(ln)
+	}
+
+	return legend
+}
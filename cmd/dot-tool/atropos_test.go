@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// blockSource serves the blocks of the given epochs, the block at failAt fails once
+type blockSource struct {
+	testSource
+	epochs  []idx.Epoch // epoch of the block n is epochs[n-1]
+	latest  idx.Block
+	failAt  idx.Block
+	fetched []idx.Block
+}
+
+func blockAtropos(n idx.Block, epoch idx.Epoch) hash.Event {
+	var h hash.Event
+	copy(h[0:4], epoch.Bytes())
+	h[31] = byte(n)
+	return h
+}
+
+func (s *blockSource) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	switch method {
+	case "eth_blockNumber":
+		*result.(*hexutil.Uint64) = hexutil.Uint64(s.latest)
+	case "eth_getBlockByNumber":
+		n, _ := hexutil.DecodeUint64(args[0].(string))
+		if idx.Block(n) == s.failAt {
+			s.failAt = 0
+			return errors.New("timeout")
+		}
+		s.fetched = append(s.fetched, idx.Block(n))
+		*result.(*rpcBlock) = rpcBlock{
+			Number: hexutil.Uint64(n),
+			Hash:   common.Hash(blockAtropos(idx.Block(n), s.epochs[n-1])),
+		}
+	}
+	return nil
+}
+
+func TestAtropoi(t *testing.T) {
+	src := &blockSource{epochs: []idx.Epoch{1, 1, 2, 2, 2, 2, 3}, latest: 4}
+	tracker := newAtroposTracker(src)
+	blocks := func(epoch idx.Epoch) []idx.Block {
+		atropoi, err := tracker.Atropoi(context.Background(), epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := make([]idx.Block, len(atropoi))
+		for i, a := range atropoi {
+			res[i] = a.Block
+			if a.Atropos != blockAtropos(a.Block, epoch) {
+				t.Errorf("block %d: atropos %s", a.Block, a.Atropos)
+			}
+		}
+		return res
+	}
+	equal := func(a, b []idx.Block) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	// the walk stops at the first block of the previous epoch
+	if got := blocks(2); !equal(got, []idx.Block{3, 4}) || !equal(src.fetched, []idx.Block{4, 3, 2}) {
+		t.Errorf("blocks %v, fetched %v", got, src.fetched)
+	}
+
+	// a failed walk keeps no blocks, so the blocks below the failure are fetched again
+	src.latest, src.failAt, src.fetched = 6, 5, nil
+	if _, err := tracker.Atropoi(context.Background(), 2); err == nil {
+		t.Fatal("no error")
+	}
+	if got := blocks(2); !equal(got, []idx.Block{3, 4, 5, 6}) {
+		t.Errorf("blocks after the failure %v", got)
+	}
+
+	// the blocks of the older epochs are dropped except the last one
+	src.latest = 7
+	if got := blocks(3); !equal(got, []idx.Block{7}) || len(tracker.blocks) != 2 {
+		t.Errorf("blocks %v, kept %v", got, tracker.blocks)
+	}
+}
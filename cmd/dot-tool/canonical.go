@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// canonicalName names the graph by the epoch and the digest of the heads,
+// the same heads give the same name whatever order the node returns them in.
+func canonicalName(epoch idx.Epoch, top hash.Events) string {
+	heads := make([]string, len(top))
+	for i, h := range top {
+		heads[i] = h.Hex()
+	}
+	sort.Strings(heads)
+	digest := sha256.Sum256([]byte(strings.Join(heads, ",")))
+	return fmt.Sprintf("DAG-EPOCH-%d-%s", epoch, hex.EncodeToString(digest[:6]))
+}
+
+// canonicalize orders the objects of the graph independently of the walk order:
+// helper nodes by name, then events and their stubs by epoch, lamport, creator and seq,
+// then subgraphs in the order they were added, then edges by their events.
+func canonicalize(g *dot.Graph, nodes map[hash.Event]*types.EventNode) {
+	events := make(map[string]*types.EventNode, len(nodes))
+	for _, p := range nodes {
+		events[p.NodeName] = p
+	}
+	event := func(n *dot.Node) *types.EventNode {
+		return events[strings.TrimPrefix(n.Name(), "stub-")]
+	}
+	// compareNodes returns -1, 0 or 1 like strings.Compare
+	compareNodes := func(a, b *dot.Node) int {
+		ea, eb := event(a), event(b)
+		switch {
+		case ea == nil && eb != nil:
+			return -1
+		case ea != nil && eb == nil:
+			return 1
+		case ea != nil && eb != nil && ea != eb:
+			for _, d := range [][2]uint64{
+				{uint64(ea.Epoch()), uint64(eb.Epoch())},
+				{uint64(ea.Lamport()), uint64(eb.Lamport())},
+				{uint64(ea.Creator()), uint64(eb.Creator())},
+				{uint64(ea.Seq()), uint64(eb.Seq())},
+			} {
+				if d[0] != d[1] {
+					if d[0] < d[1] {
+						return -1
+					}
+					return 1
+				}
+			}
+		}
+		return strings.Compare(a.Name(), b.Name())
+	}
+	rank := func(o dot.GraphObject) int {
+		switch o.(type) {
+		case *dot.Node:
+			return 0
+		case *dot.SubGraph:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	g.SortObjects(func(a, b dot.GraphObject) bool {
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		switch oa := a.(type) {
+		case *dot.Node:
+			return compareNodes(oa, b.(*dot.Node)) < 0
+		case *dot.Edge:
+			ob := b.(*dot.Edge)
+			if c := compareNodes(oa.Source(), ob.Source()); c != 0 {
+				return c < 0
+			}
+			return compareNodes(oa.Destination(), ob.Destination()) < 0
+		}
+		return false
+	})
+}
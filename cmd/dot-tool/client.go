@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Fantom-foundation/go-opera/ftmclient"
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// eventSource provides the DAG to the capture loop: a node or a record
+type eventSource interface {
+	// Now returns the time of the last heads
+	Now() time.Time
+	GetHeads(ctx context.Context, epoch *big.Int) (hash.Events, error)
+	GetEvent(ctx context.Context, h hash.Event) (json.RawMessage, error)
+	GetEvents(ctx context.Context, hh hash.Events) ([]json.RawMessage, error)
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// headsNotifier is an event source which notifies about new heads instead of being polled
+type headsNotifier interface {
+	// SubscribeHeads returns a channel which receives a value on new heads
+	// and is closed when the subscription is dropped
+	SubscribeHeads(ctx context.Context) (<-chan struct{}, error)
+}
+
+// rpcClient wraps ftmclient.Client to survive node restarts and timeouts.
+// Failed calls are retried with exponential backoff over a new connection.
+// It is safe for concurrent use.
+type rpcClient struct {
+	url     string
+	timeout time.Duration
+	retries int  // 0 - retry forever
+	withTxs bool // fetch event payloads with transaction hashes
+
+	mu   sync.Mutex
+	conn *rpc.Client
+}
+
+func newRPCClient(url string, cfg *Config) *rpcClient {
+	return &rpcClient{
+		url:     url,
+		timeout: cfg.RPCTimeout,
+		retries: cfg.RPCRetries,
+		withTxs: cfg.TxCounts,
+	}
+}
+
+func (c *rpcClient) connect() (*rpc.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		conn, err := rpc.Dial(c.url)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	return c.conn, nil
+}
+
+// reset drops the failed connection unless it is already replaced
+func (c *rpcClient) reset(conn *rpc.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn != nil && c.conn == conn {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// permanent is true for the errors a retry can not fix: the node answered,
+// but the data is missing or the request is wrong. HTTP 4xx statuses are permanent
+// except for a timeout and too many requests.
+func permanent(err error) bool {
+	var rpcErr rpc.Error
+	var httpErr rpc.HTTPError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &httpErr) {
+		code := httpErr.StatusCode
+		return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+	}
+	return errors.Is(err, ethereum.NotFound) || errors.Is(err, rpc.ErrNoResult) ||
+		errors.As(err, &rpcErr) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
+
+// retry calls fn until it succeeds, the retries are over or ctx is done.
+// Only transport errors and timeouts are retried, permanent errors are returned at once.
+func (c *rpcClient) retry(ctx context.Context, what string, fn func(ctx context.Context, conn *rpc.Client) error) error {
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		conn, err := c.call(ctx, fn)
+		if err == nil {
+			return nil
+		}
+		if permanent(err) || ctx.Err() != nil {
+			return err
+		}
+		if c.retries > 0 && attempt >= c.retries {
+			return err
+		}
+		log.Printf("Can not %s (attempt %d), retry in %s: %s\n", what, attempt, backoff, err)
+
+		c.reset(conn)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (c *rpcClient) call(ctx context.Context, fn func(ctx context.Context, conn *rpc.Client) error) (*rpc.Client, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return conn, fn(ctx, conn)
+}
+
+func (c *rpcClient) Now() time.Time {
+	return time.Now()
+}
+
+func (c *rpcClient) GetHeads(ctx context.Context, epoch *big.Int) (top hash.Events, err error) {
+	err = c.retry(ctx, "get top events", func(ctx context.Context, conn *rpc.Client) (err error) {
+		top, err = ftmclient.NewClient(conn).GetHeads(ctx, epoch)
+		return
+	})
+	return
+}
+
+// eventCall returns the method and arguments to get the event
+func (c *rpcClient) eventCall(h hash.Event) (string, []interface{}) {
+	if c.withTxs {
+		return "dag_getEventPayload", []interface{}{h.Hex(), true}
+	}
+	return "dag_getEvent", []interface{}{h.Hex()}
+}
+
+// GetEvent returns the event as raw JSON of dag_getEvent or dag_getEventPayload
+func (c *rpcClient) GetEvent(ctx context.Context, h hash.Event) (raw json.RawMessage, err error) {
+	method, args := c.eventCall(h)
+	err = c.retry(ctx, "get event "+h.String(), func(ctx context.Context, conn *rpc.Client) error {
+		return conn.CallContext(ctx, &raw, method, args...)
+	})
+	return
+}
+
+// GetEvents fetches the raw events with a single JSON-RPC batch call
+func (c *rpcClient) GetEvents(ctx context.Context, hh hash.Events) (raws []json.RawMessage, err error) {
+	err = c.retry(ctx, "get events batch", func(ctx context.Context, conn *rpc.Client) error {
+		raws = make([]json.RawMessage, len(hh))
+		batch := make([]rpc.BatchElem, len(hh))
+		for i, h := range hh {
+			method, args := c.eventCall(h)
+			batch[i] = rpc.BatchElem{
+				Method: method,
+				Args:   args,
+				Result: &raws[i],
+			}
+		}
+		if err := conn.BatchCallContext(ctx, batch); err != nil {
+			return err
+		}
+		for _, b := range batch {
+			if b.Error != nil {
+				return b.Error
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (c *rpcClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return c.retry(ctx, "call "+method, func(ctx context.Context, conn *rpc.Client) error {
+		return conn.CallContext(ctx, result, method, args...)
+	})
+}
+
+// SubscribeHeads subscribes to new blocks, every block means new heads.
+// Subscriptions work only over ws:// and ipc connections.
+func (c *rpcClient) SubscribeHeads(ctx context.Context) (<-chan struct{}, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	blocks := make(chan json.RawMessage)
+	sub, err := conn.EthSubscribe(ctx, blocks, "newHeads")
+	if err != nil {
+		return nil, err
+	}
+
+	notify := make(chan struct{}, 1)
+	go func() {
+		defer close(notify)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-sub.Err():
+				if err != nil {
+					log.Printf("New heads subscription dropped: %s\n", err)
+				}
+				return
+			case <-blocks:
+				// coalesce the blocks arrived during a capture loop
+				select {
+				case notify <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return notify, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// codeError is an error answered by the node
+type codeError struct{}
+
+func (codeError) Error() string  { return "execution reverted" }
+func (codeError) ErrorCode() int { return 3 }
+
+func TestPermanent(t *testing.T) {
+	var v struct{}
+	syntaxErr := json.Unmarshal([]byte("{"), &v)
+	typeErr := json.Unmarshal([]byte("1"), &v)
+
+	cases := []struct {
+		err       error
+		permanent bool
+	}{
+		{ethereum.NotFound, true},
+		{rpc.ErrNoResult, true},
+		{codeError{}, true},
+		{syntaxErr, true},
+		{typeErr, true},
+		{rpc.HTTPError{StatusCode: 404, Status: "404 Not Found"}, true},
+		{fmt.Errorf("call: %w", rpc.HTTPError{StatusCode: 403, Status: "403 Forbidden"}), true},
+		{rpc.HTTPError{StatusCode: 408, Status: "408 Request Timeout"}, false},
+		{rpc.HTTPError{StatusCode: 429, Status: "429 Too Many Requests"}, false},
+		{rpc.HTTPError{StatusCode: 502, Status: "502 Bad Gateway"}, false},
+		{io.EOF, false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, c := range cases {
+		if permanent(c.err) != c.permanent {
+			t.Errorf("%v: permanent is not %t", c.err, c.permanent)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	transient := errors.New("connection refused")
+	cases := []struct {
+		name     string
+		retries  int
+		errs     []error // errors of the attempts, the next attempts succeed
+		err      error
+		attempts int
+	}{
+		{"success", 3, nil, nil, 1},
+		{"transient", 3, []error{transient, transient}, nil, 3},
+		{"permanent", 3, []error{transient, ethereum.NotFound}, ethereum.NotFound, 2},
+		{"retries are over", 2, []error{transient, transient, transient}, transient, 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// the HTTP client connects on the first request, fn makes no requests
+			client := &rpcClient{url: "http://127.0.0.1:1", retries: c.retries}
+			attempts := 0
+			err := client.retry(context.Background(), "test", func(ctx context.Context, conn *rpc.Client) error {
+				attempts++
+				if attempts <= len(c.errs) {
+					return c.errs[attempts-1]
+				}
+				return nil
+			})
+			if err != c.err || attempts != c.attempts {
+				t.Errorf("'%v' after %d attempts instead of '%v' after %d", err, attempts, c.err, c.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &rpcClient{url: "http://127.0.0.1:1"}
+	attempts := 0
+	refused := errors.New("connection refused")
+	start := time.Now()
+	// the error of the attempt is returned at once
+	err := client.retry(ctx, "test", func(ctx context.Context, conn *rpc.Client) error {
+		attempts++
+		cancel()
+		return refused
+	})
+	if err != refused || attempts != 1 {
+		t.Errorf("'%v' after %d attempts", err, attempts)
+	}
+	if time.Since(start) >= minBackoff {
+		t.Errorf("canceled retry waited %s", time.Since(start))
+	}
+}
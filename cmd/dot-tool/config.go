@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The config file is a YAML mapping of the flag names to their values,
+// lists are joined by commas. The "styles" section sets the style-* flags
+// and the "profiles" section keeps named sets of settings over the top level.
+// Flags given on the command line override the file.
+
+const (
+	configStyles   = "styles"
+	configProfiles = "profiles"
+)
+
+// applyConfigFile sets the flags not given on the command line from the config file
+func applyConfigFile(fs *flag.FlagSet, path, profile string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(root.Content) == 0 {
+		if profile != "" {
+			return fmt.Errorf("%s: profile %q is not defined", path, profile)
+		}
+		return nil
+	}
+
+	settings := make(map[string]*yaml.Node)
+	var profiles *yaml.Node
+	if err = collectSettings(path, root.Content[0], settings, &profiles); err != nil {
+		return err
+	}
+	if profile != "" {
+		node, names := findProfile(profiles, profile)
+		if node == nil {
+			return fmt.Errorf("%s: profile %q is not defined, profiles: %s", path, profile, strings.Join(names, ", "))
+		}
+		if err = collectSettings(path, node, settings, nil); err != nil {
+			return err
+		}
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node := settings[name]
+		if fs.Lookup(name) == nil || name == "config" || name == "profile" {
+			return fmt.Errorf("%s:%d: unknown setting %q for %s", path, node.Line, name, fs.Name())
+		}
+		value, err := settingValue(node)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", path, node.Line, name, err)
+		}
+		if given[name] {
+			continue
+		}
+		if err = fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: invalid value %q of %s: %w", path, node.Line, value, name, err)
+		}
+	}
+	return nil
+}
+
+// collectSettings adds the settings of the mapping, profiles are allowed only if the pointer is given
+func collectSettings(path string, m *yaml.Node, settings map[string]*yaml.Node, profiles **yaml.Node) error {
+	if m.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s:%d: settings must be a mapping", path, m.Line)
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, value := m.Content[i], m.Content[i+1]
+		switch key.Value {
+		case configProfiles:
+			if profiles == nil {
+				return fmt.Errorf("%s:%d: profiles can not be nested", path, key.Line)
+			}
+			if value.Kind != yaml.MappingNode {
+				return fmt.Errorf("%s:%d: profiles must be a mapping of names to settings", path, value.Line)
+			}
+			*profiles = value
+		case configStyles:
+			if value.Kind != yaml.MappingNode {
+				return fmt.Errorf("%s:%d: styles must be a mapping", path, value.Line)
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				settings["style-"+value.Content[j].Value] = value.Content[j+1]
+			}
+		default:
+			settings[key.Value] = value
+		}
+	}
+	return nil
+}
+
+// findProfile returns the settings of the profile and the names of all the profiles
+func findProfile(profiles *yaml.Node, name string) (*yaml.Node, []string) {
+	if profiles == nil {
+		return nil, nil
+	}
+	names := make([]string, 0, len(profiles.Content)/2)
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		if profiles.Content[i].Value == name {
+			return profiles.Content[i+1], nil
+		}
+		names = append(names, profiles.Content[i].Value)
+	}
+	return nil, names
+}
+
+// settingValue returns the flag value of a scalar or a list of scalars
+func settingValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("list items must be values")
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("must be a value or a list of values")
+}
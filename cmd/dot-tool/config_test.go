@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testFlags returns the flags of a subcommand, render has no capture flags
+func testFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(new(strings.Builder))
+	fs.String("config", "", "")
+	fs.String("profile", "", "")
+	fs.String("out", "", "")
+	fs.String("style-root", "yellow", "")
+	if name == "dot-tool" {
+		fs.String("host", "localhost", "")
+		fs.Int("port", 18545, "")
+		fs.String("endpoints", "", "")
+	}
+	return fs
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	const file = `host: node1
+port: 3000
+endpoints: [a:1, b:2]
+styles:
+  root: red
+profiles:
+  local:
+    host: localhost
+    port: 4000
+`
+	cases := []struct {
+		name    string
+		command string
+		config  string
+		profile string
+		args    []string
+		values  map[string]string
+		err     string
+	}{
+		{
+			name:   "top level",
+			config: file,
+			values: map[string]string{"host": "node1", "port": "3000", "endpoints": "a:1,b:2", "style-root": "red"},
+		},
+		{
+			name:    "profile",
+			config:  file,
+			profile: "local",
+			values:  map[string]string{"host": "localhost", "port": "4000", "style-root": "red"},
+		},
+		{
+			name:    "command line",
+			config:  file,
+			profile: "local",
+			args:    []string{"-port", "5000", "-style-root", "blue"},
+			values:  map[string]string{"host": "localhost", "port": "5000", "style-root": "blue"},
+		},
+		{
+			name:   "unknown key",
+			config: "hots: node1\n",
+			err:    `:1: unknown setting "hots" for dot-tool`,
+		},
+		{
+			name:   "config in config",
+			config: "config: other.yaml\n",
+			err:    `unknown setting "config"`,
+		},
+		{
+			name:    "unknown profile",
+			config:  file,
+			profile: "remote",
+			err:     `profile "remote" is not defined, profiles: local`,
+		},
+		{
+			name:    "profile of empty file",
+			profile: "local",
+			err:     `profile "local" is not defined`,
+		},
+		{
+			name:    "nested profiles",
+			config:  "profiles:\n  local:\n    profiles:\n      inner:\n        port: 1\n",
+			profile: "local",
+			err:     ":3: profiles can not be nested",
+		},
+		{
+			name:   "bad value",
+			config: "port: many\n",
+			err:    `:1: invalid value "many" of port`,
+		},
+		{
+			name:   "nested value",
+			config: "port:\n  value: 1\n",
+			err:    "port: must be a value or a list of values",
+		},
+		{
+			name:   "nested list",
+			config: "endpoints: [[a:1]]\n",
+			err:    "endpoints: list items must be values",
+		},
+		{
+			name:    "not for the subcommand",
+			command: "dot-tool render",
+			config:  "out: images\nhost: node1\n",
+			err:     `:2: unknown setting "host" for dot-tool render`,
+		},
+		{
+			name:   "not a mapping",
+			config: "- host\n",
+			err:    ":1: settings must be a mapping",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dot-tool.yaml")
+			if err := os.WriteFile(path, []byte(c.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			command := c.command
+			if command == "" {
+				command = "dot-tool"
+			}
+			fs := testFlags(command)
+			if err := fs.Parse(c.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyConfigFile(fs, path, c.profile)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("'%v' is not '%s'", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range c.values {
+				if got := fs.Lookup(name).Value.String(); got != value {
+					t.Errorf("%s: %q != %q", name, got, value)
+				}
+			}
+		})
+	}
+}
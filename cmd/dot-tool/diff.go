@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+var (
+	colorOnlyLeft  = dot.MustParseColor("blue")
+	colorOnlyRight = dot.MustParseColor("red")
+	colorChanged   = dot.RGB(0xFF, 0xAA, 0xAA)
+)
+
+// diffCommand compares the DAGs of two .dot captures by event IDs and parent edges.
+// It returns the exit code: 0 - same DAGs, 1 - different DAGs, 2 - trouble.
+func diffCommand(args []string) int {
+	var out string
+
+	fs := flag.NewFlagSet("dot-tool diff", flag.ExitOnError)
+	fs.StringVar(&out, "out", "", "File to write the merged graph with colored differences")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dot-tool diff [-out merged.dot] left.dot right.dot\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	leftPath, rightPath := fs.Arg(0), fs.Arg(1)
+
+	left, err := loadGraph(leftPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can not load '%s': %s\n", leftPath, err)
+		return 2
+	}
+	right, err := loadGraph(rightPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can not load '%s': %s\n", rightPath, err)
+		return 2
+	}
+
+	d := types.DiffGraphs(left, right)
+	printDiff(d, leftPath, rightPath)
+
+	if out != "" {
+		merged, err := d.MergedGraph("DAG-DIFF", colorOnlyLeft, colorOnlyRight, colorChanged)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can not build the merged graph: %s\n", err)
+			return 2
+		}
+		if err = merged.WriteFile(out, strings.HasSuffix(out, ".gz")); err != nil {
+			fmt.Fprintf(os.Stderr, "Can not write data to file '%s': %s\n", out, err)
+			return 2
+		}
+	}
+
+	if d.Empty() {
+		return 0
+	}
+	return 1
+}
+
+// loadGraph reads a plain or gzip compressed .dot file
+func loadGraph(path string) (*dot.Graph, error) {
+	return dot.ParseFile(path)
+}
+
+func printDiff(d *types.DagDiff, leftPath, rightPath string) {
+	fmt.Printf("%s: %d events, %s: %d events\n", leftPath, len(d.Left), rightPath, len(d.Right))
+
+	if len(d.OnlyLeft) > 0 {
+		fmt.Printf("only in %s: %d events\n", leftPath, len(d.OnlyLeft))
+		for _, id := range d.OnlyLeft {
+			fmt.Printf("  %s (%s)\n", id, d.Left[id].Group)
+		}
+	}
+	if len(d.OnlyRight) > 0 {
+		fmt.Printf("only in %s: %d events\n", rightPath, len(d.OnlyRight))
+		for _, id := range d.OnlyRight {
+			fmt.Printf("  %s (%s)\n", id, d.Right[id].Group)
+		}
+	}
+	if len(d.Frames) > 0 {
+		fmt.Printf("different frames: %d events\n", len(d.Frames))
+		for _, id := range d.Frames {
+			fmt.Printf("  %s: %s != %s\n", id, d.Left[id].Frame, d.Right[id].Frame)
+		}
+	}
+	if len(d.Parents) > 0 {
+		fmt.Printf("divergent parents: %d events\n", len(d.Parents))
+		for _, id := range d.Parents {
+			fmt.Printf("  %s: [%s] != [%s]\n", id,
+				strings.Join(d.Left[id].Parents, " "), strings.Join(d.Right[id].Parents, " "))
+		}
+	}
+	if d.Empty() {
+		fmt.Println("same DAG")
+	}
+}
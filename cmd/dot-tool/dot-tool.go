@@ -4,15 +4,18 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/Fantom-foundation/go-opera/ftmclient"
 	"github.com/Fantom-foundation/lachesis-base/hash"
 	"github.com/Fantom-foundation/lachesis-base/inter/idx"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -24,16 +27,24 @@ import (
 
 // The main entry point of the dagtool.
 
-const (
-	colorRoot    = "#FFFF00"
-	colorNewRoot = "#AAAA00"
-	colorOldRoot = "#888888"
+var (
+	colorRoot    = dot.RGB(0xFF, 0xFF, 0x00)
+	colorNewRoot = dot.RGB(0xAA, 0xAA, 0x00)
+	colorOldRoot = dot.RGB(0x88, 0x88, 0x88)
+	colorNew     = dot.MustParseColor("red")
 )
 
 var (
 	LatestSealedEpoch = big.NewInt(-1)
 )
 
+const (
+	// subscribedPoll is the poll interval while subscribed, heads change between blocks too
+	subscribedPoll = 10 * time.Second
+	// resubscribeDelay is the pause before a failed subscription is tried again
+	resubscribeDelay = 10 * time.Second
+)
+
 // configs
 type Config struct {
 	RPCHost    string
@@ -42,63 +53,410 @@ type Config struct {
 	LvlLimit   int
 	OnlyEpoch  bool
 	RenderFile bool
+	Atropos    bool
+	RPCTimeout time.Duration
+	RPCRetries int
+	BatchSize  int
+	Workers    int
+	CachePath  string
+	RecordPath string
+	ReplayPath string
+	Endpoints  []string
+	Formats    []string
+	TxCounts   bool
+	Listen     string
+	History    int
+	Subscribe  bool
+	Epoch      int64
+	Window     window
+	Validators bool
+	ByStake    bool
+	Label      *types.LabelTemplate
+	Styles     Styles
+	Gzip       bool
+	Canonical  bool
+	Table      bool
+}
+
+// Styles are the colors of roots and changes
+type Styles struct {
+	Root        dot.Color
+	NewRoot     dot.Color
+	OldRoot     dot.Color
+	New         dot.Color
+	NewPenWidth float64
 }
 
 // main function
 func main() {
+	command := ""
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "", "record", "replay", "serve":
+		ProcessLoop(parseConfig(command, args))
+	case "diff":
+		os.Exit(diffCommand(args))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q, use: dot-tool [record|replay|serve|diff] [flags]\n", command)
+		os.Exit(1)
+	}
+}
+
+// parseConfig reads the flags of the capture commands
+func parseConfig(command string, args []string) Config {
 	var cfg Config
 	var mode string
 
-	flag.StringVar(&cfg.RPCHost, "host", "localhost", "Host for RPC requests")
-	flag.IntVar(&cfg.RPCPort, "port", 18545, "Port for RPC requests")
-	flag.IntVar(&cfg.LvlLimit, "limit", 0, "DAG level limit")
-	flag.StringVar(&cfg.OutPath, "out", "", "Path of directory for save DOT files")
-	flag.StringVar(&mode, "mode", "root", "Mode:\nroot - single shot to every root node changes\nepoch - single shot to every epoch")
-	flag.BoolVar(&cfg.RenderFile, "render", true, "Render:\n true - render dot file to png image\n false - no rendering")
-	flag.Parse()
+	fs := flag.NewFlagSet(strings.TrimSpace("dot-tool "+command), flag.ExitOnError)
+	if command != "replay" {
+		fs.StringVar(&cfg.RPCHost, "host", "localhost", "Host for RPC requests")
+		fs.IntVar(&cfg.RPCPort, "port", 18545, "Port for RPC requests")
+		fs.DurationVar(&cfg.RPCTimeout, "timeout", 10*time.Second, "Timeout of a single RPC call")
+		fs.IntVar(&cfg.RPCRetries, "retries", 10, "Attempts of a failed RPC call before the capture loop is restarted (0 - retry forever)")
+		fs.IntVar(&cfg.BatchSize, "batch", 64, "Events per JSON-RPC batch call (0 - fetch events one by one)")
+		fs.IntVar(&cfg.Workers, "workers", 4, "Concurrent batch calls")
+		fs.BoolVar(&cfg.TxCounts, "txs", false, "Fetch event payloads to count transactions")
+		fs.BoolVar(&cfg.Subscribe, "subscribe", false, "Subscribe to new heads over ws:// instead of polling, host and port are of the WebSocket RPC")
+	}
+	var endpoints string
+	if command == "" || command == "serve" {
+		fs.StringVar(&endpoints, "endpoints", "", "Comma separated host:port list of the nodes to capture at once, instead of host and port")
+	}
+	switch command {
+	case "record":
+		fs.StringVar(&cfg.RecordPath, "record", "", "File to record fetched events and heads")
+	case "replay":
+		fs.StringVar(&cfg.ReplayPath, "in", "", "File recorded by the record command")
+	case "serve":
+		fs.StringVar(&cfg.Listen, "listen", "localhost:8080", "Address of the HTTP viewer")
+		fs.IntVar(&cfg.History, "history", 100, "Snapshots kept by the viewer (0 - all)")
+	}
+	fs.IntVar(&cfg.LvlLimit, "limit", 0, "DAG level limit")
+	fs.StringVar(&cfg.OutPath, "out", "", "Path of directory for save DOT files")
+	fs.StringVar(&mode, "mode", "root", "Mode:\nroot - single shot to every root node changes\nepoch - single shot to every epoch")
+	fs.BoolVar(&cfg.Gzip, "gzip", false, "Write gzip compressed .dot.gz files")
+	fs.BoolVar(&cfg.Canonical, "canonical", false, "Write reproducible graphs: named by epoch and heads, objects ordered by epoch, lamport, creator and seq")
+	fs.BoolVar(&cfg.RenderFile, "render", true, "Render:\n true - render dot file to png image\n false - no rendering")
+	fs.StringVar(&cfg.CachePath, "cache", "", "File to keep fetched events between runs (empty - memory only)")
+	fs.BoolVar(&cfg.Atropos, "atropos", false, "Mark Atropos events and the events they confirm")
+	formats := fs.String("format", formatDot, "Comma separated output formats: "+strings.Join(outputFormats, ", "))
+	fs.BoolVar(&cfg.Validators, "validators", false, "Show stakes and addresses of the validators in the creator clusters")
+	label := fs.String("label", types.DefaultLabel, "text/template of the event labels over types.EventView")
+	fs.BoolVar(&cfg.Table, "table", false, "Show the event fields in a table, parent edges start from the cells of the parents")
+	order := fs.String("order", "id", "Order of the creator clusters: id, stake")
+	fs.Int64Var(&cfg.Epoch, "epoch", 0, "Epoch to capture (0 - the current one)")
+	lamports := fs.String("lamport", "", "Lamport range from..to of the events to draw, either bound may be omitted")
+	frames := fs.String("frames", "", "Frame range from..to of the events to draw, either bound may be omitted")
+	times := fs.String("time", "", "Creation time range from..to of the events to draw in RFC3339, either bound may be omitted")
+	cfg.Styles = Styles{colorRoot, colorNewRoot, colorOldRoot, colorNew, 2.5}
+	fs.Var(colorFlag{&cfg.Styles.Root}, "style-root", "Fill color of the root events")
+	fs.Var(colorFlag{&cfg.Styles.NewRoot}, "style-new-root", "Fill color of the events became roots")
+	fs.Var(colorFlag{&cfg.Styles.OldRoot}, "style-old-root", "Fill color of the events stopped being roots")
+	fs.Var(colorFlag{&cfg.Styles.New}, "style-new", "Color of the new events and edges")
+	fs.Float64Var(&cfg.Styles.NewPenWidth, "style-new-penwidth", 2.5, "Pen width of the new events and edges")
+	configPath := fs.String("config", "", "YAML file with the flag values, the flags override it")
+	profile := fs.String("profile", "", "Profile of the config file")
+	_ = fs.Parse(args)
+
+	if *configPath != "" {
+		if err := applyConfigFile(fs, *configPath, *profile); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid config: %s\n", err)
+			os.Exit(1)
+		}
+	} else if *profile != "" {
+		fmt.Fprintln(os.Stderr, "Profile requires a config file")
+		os.Exit(1)
+	}
 
-	if cfg.OutPath == "" {
-		flag.PrintDefaults()
+	if cfg.OutPath == "" && command != "serve" ||
+		command == "record" && cfg.RecordPath == "" ||
+		command == "replay" && cfg.ReplayPath == "" {
+		fs.PrintDefaults()
 		os.Exit(1)
 	}
 
+	if mode != "root" && mode != "epoch" {
+		fmt.Fprintf(os.Stderr, "Unknown mode %q\n", mode)
+		os.Exit(1)
+	}
 	cfg.OnlyEpoch = mode == "epoch"
+	var err error
+	if cfg.Label, err = types.ParseLabel(*label); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid label template: %s\n", err)
+		os.Exit(1)
+	}
+	switch *order {
+	case "id":
+	case "stake":
+		cfg.ByStake = true
+		cfg.Validators = true
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown clusters order %q\n", *order)
+		os.Exit(1)
+	}
+	w := &cfg.Window
+	var errs [3]error
+	w.MinLamport, w.MaxLamport, errs[0] = parseRange(*lamports)
+	w.MinFrame, w.MaxFrame, errs[1] = parseRange(*frames)
+	w.Since, w.Until, errs[2] = parseTimeRange(*times)
+	for _, err := range errs {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid window: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, format := range strings.Split(*formats, ",") {
+		format = strings.TrimSpace(format)
+		if indexOf(outputFormats, format) < 0 {
+			fmt.Fprintf(os.Stderr, "Unknown output format %q\n", format)
+			os.Exit(1)
+		}
+		cfg.Formats = append(cfg.Formats, format)
+	}
+	for _, endpoint := range strings.Split(endpoints, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			cfg.Endpoints = append(cfg.Endpoints, endpointURL(endpoint, cfg.Subscribe))
+		}
+	}
+	if len(cfg.Endpoints) == 0 {
+		scheme := "http"
+		if cfg.Subscribe {
+			scheme = "ws"
+		}
+		cfg.Endpoints = []string{fmt.Sprintf("%s://%s:%d/", scheme, cfg.RPCHost, cfg.RPCPort)}
+	}
+
+	return cfg
+}
+
+// openSource connects to the node, records it or replays a record
+func openSource(cfg *Config, store *eventStore) (eventSource, error) {
+	if cfg.ReplayPath != "" {
+		return openReplay(cfg.ReplayPath, store)
+	}
 
-	ProcessLoop(cfg)
+	r := newRPCClient(cfg.Endpoints[0], cfg)
+	if cfg.RecordPath != "" {
+		return newRecorder(r, cfg.RecordPath, store)
+	}
+	return r, nil
 }
 
 func ProcessLoop(cfg Config) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	url := fmt.Sprintf("http://%s:%d/", cfg.RPCHost, cfg.RPCPort)
-	conn, err := rpc.Dial(url)
+	store, err := openEventStore(cfg.CachePath)
 	if err != nil {
-		log.Panicf("Can not connect RPC: %s\n", err)
+		log.Panicf("Can not open events cache: %s\n", err)
+	}
+	defer store.Close()
+
+	var v *viewer
+	if cfg.Listen != "" {
+		v = newViewer(cfg.History)
+		go v.listen(ctx, cfg.Listen)
+	}
+
+	if len(cfg.Endpoints) <= 1 {
+		r, err := openSource(&cfg, store)
+		if err != nil {
+			log.Panicf("Can not open events source: %s\n", err)
+		}
+		if closer, ok := r.(io.Closer); ok {
+			defer closer.Close()
+		}
+
+		c := &capture{
+			cfg:   cfg,
+			src:   r,
+			store: store,
+			log:   log.Default(),
+		}
+		if v != nil {
+			c.publish = v.publisher("")
+		}
+		c.run(ctx)
+		return
+	}
+
+	// poll all the nodes concurrently, every node has its own output directory
+	u := newUnion(cfg, store, len(cfg.Endpoints))
+	if v != nil {
+		u.publish = v.publisher("union")
+	}
+	var wg sync.WaitGroup
+	for i, url := range cfg.Endpoints {
+		name := strconv.Itoa(i + 1)
+		nodeCfg := cfg
+		if cfg.OutPath != "" {
+			nodeCfg.OutPath = filepath.Join(cfg.OutPath, name)
+			if err = os.MkdirAll(nodeCfg.OutPath, 0755); err != nil {
+				log.Panicf("Can not create directory '%s': %s\n", nodeCfg.OutPath, err)
+			}
+		}
+		log.Printf("Node %s: %s, output to '%s'\n", name, url, nodeCfg.OutPath)
+
+		c := &capture{
+			cfg:      nodeCfg,
+			src:      newRPCClient(url, &cfg),
+			store:    store,
+			log:      log.New(os.Stderr, "["+name+"] ", log.LstdFlags),
+			snapshot: u.snapshot(i),
+		}
+		if v != nil {
+			c.publish = v.publisher(name)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// capture takes the DAG snapshots of a single node
+type capture struct {
+	cfg   Config
+	src   eventSource
+	store *eventStore
+	log   *log.Logger
+	// snapshot, if set, gets the events of every taken graph and manages the store pruning
+	snapshot func(epoch idx.Epoch, nodes map[hash.Event]*types.EventNode)
+	// publish, if set, gets every written graph
+	publish func(g *dot.Graph)
+
+	heads         <-chan struct{} // new heads notifications, nil if polling
+	unsupported   bool            // the source can not notify
+	resubscribeAt time.Time
+}
+
+func (c *capture) run(ctx context.Context) {
+	cfg := c.cfg
+	r := c.src
+	store := c.store
+	tracker := newAtroposTracker(r)
+	validators := newValidatorTracker(r)
+	dag := types.NewDag()
+
+	headsEpoch := LatestSealedEpoch
+	if cfg.Epoch > 0 {
+		headsEpoch = big.NewInt(cfg.Epoch)
 	}
-	r := ftmclient.NewClient(conn)
-	ctx := context.TODO()
 
 	processedTop := make(map[hash.Event]bool)
 
+	// eventNode builds the node of the event, the DAG keeps it for the next loops
+	eventNode := func(p *types.EventNode) *dot.Node {
+		n := dot.NewNode(p.NodeName)
+		p.Label = cfg.Label.Label(p)
+		label := p.Label
+		if cfg.Table {
+			n.SetShape(dot.ShapeBox)
+			checkAttrs("event "+p.ID().String(), n.Set("margin", "0"))
+			label = types.EventTable(p)
+		}
+		checkAttrs("event "+p.ID().String(), n.Set("comment", p.Comment()), n.SetLabel(label))
+		if p.IsRoot {
+			n.SetStyle(dot.StyleFilled)
+			n.SetFillColor(cfg.Styles.Root)
+		}
+		// Make nodes clickable in SVG
+		if hasFormat(&cfg, formatSVG) {
+			setEventLinks(n, p)
+		}
+		return n
+	}
+	stubNode := func(p *types.EventNode) *dot.Node {
+		n := dot.NewNode("stub-" + p.NodeName)
+		setStub(n, p)
+		return n
+	}
+
 	var prevGraphData *types.GraphData
 	var prevGraph *dot.Graph
 	var prevEpoch idx.Epoch
 
 mainLoop:
-	for {
-		graphName := "DAG" + strconv.FormatInt(time.Now().UnixNano(), 10)
-
+	for ctx.Err() == nil {
 		subGraphs := make(map[string]*dot.SubGraph)
 		extEdges := make([]*dot.Edge, 0)
 		graphData := &types.GraphData{}
+		view := dag.View()
 
 		// Get top events
-		top, err := r.GetHeads(ctx, LatestSealedEpoch)
+		top, err := r.GetHeads(ctx, headsEpoch)
+		if err == io.EOF {
+			c.log.Println("Replay done")
+			return
+		}
 		if err != nil {
-			log.Panicf("Can not get top events: %s\n", err)
+			c.log.Printf("Can not get top events: %s\n", err)
+			c.wait(ctx, 1*time.Second)
+			continue mainLoop
 		}
+		graphName := "DAG" + strconv.FormatInt(r.Now().UnixNano(), 10)
 
 		nodes := make(map[hash.Event]*types.EventNode)
 		inGraph := make(map[string]*dot.Node)
+		stubs := make(map[string]*dot.Node)
+		creators := make(map[string]idx.ValidatorID)
+
+		// cluster returns the subgraph of the event creator
+		cluster := func(p *types.EventNode) *dot.SubGraph {
+			sg, ok := subGraphs[p.NodeGroup]
+			if !ok {
+				sg = dot.NewSubgraph("cluster" + strconv.FormatUint(uint64(p.Creator()), 10))
+				checkAttrs("cluster "+p.NodeGroup,
+					sg.SetStyle(dot.StyleDotted),
+					sg.SetLabel(p.NodeGroup),
+					sg.SetSortv(int(p.Creator())))
+				subGraphs[p.NodeGroup] = sg
+				creators[p.NodeGroup] = p.Creator()
+
+				pseudoNode := dot.NewNode(p.NodeGroup)
+				graphData.AddNode(pseudoNode)
+				pseudoNode.SetStyle(dot.StyleInvis)
+				checkAttrs("node "+p.NodeGroup, pseudoNode.Set("width", "0"))
+				sg.AddNode(pseudoNode)
+				inGraph[p.NodeGroup] = pseudoNode
+			}
+			return sg
+		}
+
+		// graphNode returns the node of the event, adding it to the graph if needed
+		graphNode := func(p *types.EventNode) *dot.Node {
+			if n, ok := inGraph[p.NodeName]; ok {
+				return n
+			}
+			n := view.Node(p, eventNode)
+			graphData.AddEventNode(n, p)
+			cluster(p).AddNode(n)
+			inGraph[p.NodeName] = n
+			return n
+		}
+
+		// graphStub returns the boundary stub of the event outside the window
+		graphStub := func(p *types.EventNode) *dot.Node {
+			if n, ok := stubs[p.NodeName]; ok {
+				return n
+			}
+			n := view.Stub(p, stubNode)
+			graphData.AddNode(n)
+			cluster(p).AddNode(n)
+			stubs[p.NodeName] = n
+			return n
+		}
+
+		// the graph is built of the heads and the parent edges after the walk,
+		// when the root status of the events is known
+		heads := make([]*types.EventNode, 0, len(top))
+		walked := make([]parentEdge, 0)
 
 		hashStack := stack.New()
 
@@ -108,70 +466,52 @@ mainLoop:
 		newEpoch := false
 
 		if len(top) == 0 {
-			log.Printf("No data for loop %s\n", graphName)
-			time.Sleep(1 * time.Second)
+			c.log.Printf("No data for loop %s\n", graphName)
+			c.wait(ctx, 1*time.Second)
 			continue mainLoop
 		}
 
 		for _, h := range top {
 			if processedTop[h] {
-				time.Sleep(100 * time.Millisecond)
+				c.wait(ctx, 100*time.Millisecond)
 				continue mainLoop
 			}
 			processedTop[h] = true
 
-			head, err := r.GetEvent(ctx, h)
+			p, err := fetchNode(ctx, r, store, dag, h)
 			if err != nil {
-				log.Panicf("Can not get head: %s\n", err)
+				c.log.Printf("Can not get head: %s\n", err)
+				forgetHeads(processedTop, top)
+				continue mainLoop
 			}
-			curEpoch = head.Epoch()
+			curEpoch = p.Epoch()
 
-			if head.Epoch() != prevEpoch {
+			if p.Epoch() != prevEpoch {
 				newEpoch = true
 			}
 
-			startLevel = head.Seq()
+			startLevel = p.Seq()
 
-			p := types.NewEventNode(head)
 			nodes[h] = p
+			heads = append(heads, p)
 
-			n := dot.NewNode(p.NodeName)
-			graphData.AddNode(n)
-			// TODO: restore isRoot attribute
-			/*
-				if p.IsRoot {
-					n.Set("style", "filled")
-					n.Set("fillcolor", colorRoot)
-				}*/
+			hashStack.Push(h)
+		}
 
-			sg, ok := subGraphs[p.NodeGroup]
-			if !ok {
-				idx := len(subGraphs)
-				sg = dot.NewSubgraph("cluster" + strconv.FormatInt(int64(idx), 10))
-				sg.Set("style", "dotted")
-				sg.Set("label", p.NodeGroup)
-				id, _ := strconv.ParseInt(p.GetId(), 16, 64)
-				sg.Set("sortv", strconv.FormatInt(id, 10))
-				subGraphs[p.NodeGroup] = sg
+		if cfg.Canonical {
+			graphName = canonicalName(curEpoch, top)
+		}
+		c.log.Printf("Start loop %s\n", graphName)
 
-				pseudoNode := dot.NewNode(p.NodeGroup)
-				graphData.AddNode(pseudoNode)
-				pseudoNode.Set("style", "invis")
-				pseudoNode.Set("width", "0")
-				sg.AddNode(pseudoNode)
-				inGraph[p.NodeGroup] = pseudoNode
+		if cfg.BatchSize > 0 {
+			err = prefetch(ctx, r, store, dag, &cfg, nodes, startLevel)
+			if err != nil {
+				c.log.Printf("Can not prefetch events: %s\n", err)
+				forgetHeads(processedTop, top)
+				continue mainLoop
 			}
-			n.Set("shape", "tripleoctagon")
-
-			sg.AddNode(n)
-
-			inGraph[p.NodeName] = n
-
-			hashStack.Push(h)
 		}
 
-		log.Printf("Start loop %s\n", graphName)
-
 		// log.Printf("DBG1\n", )
 
 		processed := make(map[hash.Event]bool)
@@ -187,91 +527,122 @@ mainLoop:
 			// Get current node
 			node, present := nodes[h]
 			if !present {
-				head, err := r.GetEvent(ctx, h)
+				node, err = fetchNode(ctx, r, store, dag, h)
 				if err != nil {
-					log.Panicf("Can not get head: %s\n", err)
+					c.log.Printf("Can not get head: %s\n", err)
+					forgetHeads(processedTop, top)
+					continue mainLoop
 				}
-
-				node = types.NewEventNode(head)
 			}
 
 			if cfg.LvlLimit > 0 && int(startLevel-node.Seq()) > cfg.LvlLimit {
-				log.Println("Finish DAG by limit")
+				c.log.Println("Finish DAG by limit")
 				break
 			}
-			mainNode := inGraph[node.NodeName]
+
+			// Parents of the events below the lamport or frame bounds are not needed
+			if cfg.Window.Passed(node) {
+				continue
+			}
 
 			// For all parents
-			for _, parent := range node.Parents() {
+			for i, parent := range node.Parents() {
 				// Get parent node
 				p, present := nodes[parent]
 				if !present {
-					head, err := r.GetEvent(ctx, parent)
+					p, err = fetchNode(ctx, r, store, dag, parent)
 					if err != nil {
-						log.Panicf("Can not get head: %s\n", err)
+						c.log.Printf("Can not get head: %s\n", err)
+						forgetHeads(processedTop, top)
+						continue mainLoop
 					}
 
-					p = types.NewEventNode(head)
-
 					// Save to nodes cache
 					nodes[parent] = p
 				}
+				walked = append(walked, parentEdge{node, p, i})
 
-				// Add parent node to graph
-				n, ok := inGraph[p.NodeName]
-				if !ok {
-					n = dot.NewNode(p.NodeName)
-					graphData.AddNode(n)
-					// TODO: restore isRoot attribute
-					/*
-						if p.IsRoot {
-							n.Set("style", "filled")
-							n.Set("fillcolor", colorRoot)
-						}
-					*/
-					sg, ok := subGraphs[p.NodeGroup]
-					if !ok {
-						idx := len(subGraphs)
-						sg = dot.NewSubgraph("cluster" + strconv.FormatInt(int64(idx), 10))
-						sg.Set("label", p.NodeGroup)
-						sg.Set("style", "dotted")
-						id, _ := strconv.ParseInt(p.GetId(), 16, 64)
-						sg.Set("sortv", strconv.FormatInt(id, 10))
-						subGraphs[p.NodeGroup] = sg
-
-						pseudoNode := dot.NewNode(p.NodeGroup)
-						graphData.AddNode(pseudoNode)
-						pseudoNode.Set("style", "invis")
-						pseudoNode.Set("width", "0")
-						sg.AddNode(pseudoNode)
-						inGraph[p.NodeGroup] = pseudoNode
-					}
-					sg.AddNode(n)
-					inGraph[p.NodeName] = n
+				// Add parent node for processing on next loop
+				hashStack.Push(parent)
+			}
+		}
+
+		// Fill roots, computed locally from frames of fetched events.
+		// The nodes are built after, so the labels see the root status of this loop.
+		types.MarkRoots(nodes)
+
+		// Build the graph of the view, the nodes and the edges of the known events are reused
+		for _, p := range heads {
+			if cfg.Window.Position(p) == inWindow {
+				graphNode(p).SetShape(dot.ShapeTripleOctagon)
+			}
+		}
+		for _, w := range walked {
+			pos, parentPos := cfg.Window.Position(w.event), cfg.Window.Position(w.parent)
+
+			// Add edge from main node to parent, events outside the window are stubs
+			var src, dst *dot.Node
+			switch {
+			case pos == inWindow && parentPos == inWindow:
+				src, dst = graphNode(w.event), graphNode(w.parent)
+			case pos == inWindow:
+				src, dst = graphNode(w.event), graphStub(w.parent)
+			case parentPos == inWindow:
+				src, dst = graphStub(w.event), graphNode(w.parent)
+			default:
+				continue
+			}
+			e := view.Edge(w.event, w.parent, func() *dot.Edge {
+				e := dot.NewEdge(src, dst)
+				if pos != parentPos {
+					e.SetStyle(dot.StyleDashed)
 				}
-				// Add edge from main node to parent
-				e := dot.NewEdge(mainNode, n)
-				graphData.AddEdge(e)
-				e.Set("constraint", "true")
-				if node.NodeGroup == p.NodeGroup {
-					sg, _ := subGraphs[p.NodeGroup]
-					sg.AddEdge(e)
-				} else {
-					extEdges = append(extEdges, e)
+				if cfg.Table && pos == inWindow {
+					checkAttrs("edge "+w.event.ID().String(), e.Set("tailport", types.ParentPort(w.port)+":s"))
 				}
+				e.SetConstraint(true)
+				return e
+			})
+			graphData.AddEdge(e)
+			if w.event.NodeGroup == w.parent.NodeGroup {
+				subGraphs[w.parent.NodeGroup].AddEdge(e)
+			} else {
+				extEdges = append(extEdges, e)
+			}
+		}
 
-				// Add parent node for processing on next loop
-				hashStack.Push(parent)
+		// Overlay consensus decisions
+		var legend *dot.SubGraph
+		if cfg.Atropos {
+			atropoi, err := tracker.Atropoi(ctx, curEpoch)
+			if err != nil {
+				c.log.Printf("Can not get atropos events: %s\n", err)
+				forgetHeads(processedTop, top)
+				continue mainLoop
+			}
+			legend = markDecisions(types.DecideFrames(nodes, atropoi), inGraph)
+		}
+
+		// Annotate clusters with the validator stakes
+		var vs *validatorSet
+		if cfg.Validators {
+			vs, err = validators.Validators(ctx, curEpoch)
+			if err != nil {
+				c.log.Printf("Can not get validators: %s\n", err)
+				forgetHeads(processedTop, top)
+				continue mainLoop
 			}
+			annotateClusters(vs, subGraphs, creators)
 		}
 
 		// Create graph
 		g := dot.NewGraph(graphName)
 		// set attribs to local
-		g.Set("clusterrank", "local")
-		g.Set("compound", "true")
-		g.Set("newrank", "true")
-		g.Set("ranksep", "0.05")
+		checkAttrs("graph "+g.Name(),
+			g.Set("clusterrank", "local"),
+			g.Set("compound", "true"),
+			g.Set("newrank", "true"),
+			g.Set("ranksep", "0.05"))
 
 		// Sort subgraphs names
 		subGraphsNames := make([]string, 0, len(subGraphs))
@@ -279,6 +650,18 @@ mainLoop:
 			subGraphsNames = append(subGraphsNames, sgName)
 		}
 		sort.Strings(subGraphsNames)
+		if cfg.ByStake {
+			orderByStake(subGraphsNames, vs, creators)
+			for i, sgName := range subGraphsNames {
+				checkAttrs("cluster "+sgName, subGraphs[sgName].SetSortv(i))
+			}
+		}
+		if vs != nil {
+			if label := missingLabel(vs, lastFrames(nodes)); label != "" {
+				c.log.Println(label)
+				checkAttrs("graph", g.SetLabel(label), g.Set("labelloc", "t"))
+			}
+		}
 
 		// FIXED: dot program renders subgraphs not in the ordering that specified
 		//   so we introduce pseudo nodes and edges to work around
@@ -291,28 +674,173 @@ mainLoop:
 			g.AddSubgraph(subGraphs[subName])
 		}
 
+		if legend != nil {
+			g.AddSubgraph(legend)
+		}
+
 		// Add external edges in graph
 		for _, edge := range extEdges {
 			g.AddEdge(edge)
 		}
+		if cfg.Canonical {
+			canonicalize(g, nodes)
+		}
 
-		// Compare graphs elements and mark red changes
-		graphData.MarkChanges(prevGraphData, "red", "2.5", colorRoot, colorNewRoot, colorOldRoot)
-		prevGraphData = graphData
+		// Mark red changes since the previous snapshot
+		graphData.MarkChanges(view.Snapshot(), cfg.Styles.New, cfg.Styles.NewPenWidth, cfg.Styles.NewRoot, cfg.Styles.OldRoot)
+
+		// Mark forks over the changes
+		forks := types.DetectForks(nodes)
+		if len(forks) > 0 {
+			c.log.Printf("Found %d forks\n", len(forks))
+			graphData.SetForks(forks)
+			markForks(forks, nodes, inGraph, subGraphs)
+		}
+		outData := graphData
 
 		if cfg.OnlyEpoch && newEpoch && prevGraph != nil {
 			g = prevGraph
-			log.Println("New epoch out")
+			outData = prevGraphData
+			c.log.Println("New epoch out")
 		}
+		prevGraphData = graphData
 
 		if !cfg.OnlyEpoch || prevEpoch != 0 {
-			flushToFile(&cfg, prevEpoch, g)
+			if cfg.OutPath != "" {
+				flushToFile(&cfg, prevEpoch, g, outData)
+			}
+			if c.publish != nil {
+				c.publish(g)
+			}
+		}
+
+		if err = store.Flush(); err != nil {
+			c.log.Printf("Can not write events cache: %s\n", err)
+		}
+
+		if c.snapshot != nil {
+			c.snapshot(curEpoch, nodes)
 		}
 
 		prevGraph = g
 		if newEpoch {
+			dag.Prune(prevEpoch)
+			if c.snapshot == nil {
+				// keep the previous epoch for the graph pending in epoch mode
+				store.Prune(prevEpoch)
+			}
 			prevEpoch = curEpoch
 		}
-		log.Println("Capture loop done")
+		c.log.Println("Capture loop done")
+	}
+}
+
+// wait sleeps before the next poll of heads. If subscribed, it waits for new heads instead.
+// A notification only starts the next capture loop sooner: the loop fetches the
+// events not cached yet and builds their nodes, but the graph is written as a whole.
+func (c *capture) wait(ctx context.Context, d time.Duration) {
+	if c.cfg.Subscribe && c.heads == nil && !c.unsupported && time.Now().After(c.resubscribeAt) {
+		c.subscribe(ctx)
+	}
+
+	timeout := d
+	if c.heads != nil {
+		timeout = subscribedPoll
+	}
+	select {
+	case <-ctx.Done():
+	case _, ok := <-c.heads:
+		if !ok {
+			// fall back to polling until subscribed again
+			c.heads = nil
+			c.resubscribeAt = time.Now().Add(resubscribeDelay)
+			c.log.Println("New heads subscription closed, polling")
+		}
+	case <-time.After(timeout):
+	}
+}
+
+func (c *capture) subscribe(ctx context.Context) {
+	n, ok := c.src.(headsNotifier)
+	if !ok {
+		c.unsupported = true
+		c.log.Println("Events source can not subscribe, polling")
+		return
+	}
+
+	heads, err := n.SubscribeHeads(ctx)
+	if err == rpc.ErrNotificationsUnsupported {
+		c.unsupported = true
+		c.log.Println("Subscriptions are not supported by the connection, polling")
+		return
+	}
+	if err != nil {
+		c.resubscribeAt = time.Now().Add(resubscribeDelay)
+		c.log.Printf("Can not subscribe to new heads, polling: %s\n", err)
+		return
+	}
+	c.heads = heads
+	c.log.Println("Subscribed to new heads")
+}
+
+// checkAttrs logs the errors of the attribute setters
+func checkAttrs(what string, errs ...error) {
+	for _, err := range errs {
+		if err != nil {
+			log.Printf("Can not set attributes of %s: %s\n", what, err)
+		}
+	}
+}
+
+// colorFlag is a flag of a color
+type colorFlag struct {
+	c *dot.Color
+}
+
+func (f colorFlag) String() string {
+	if f.c == nil {
+		return ""
+	}
+	return f.c.String()
+}
+
+func (f colorFlag) Set(s string) (err error) {
+	*f.c, err = dot.ParseColor(s)
+	return err
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// endpointURL makes an RPC URL of host:port, a ws:// one to subscribe. URLs are kept as is.
+func endpointURL(endpoint string, subscribe bool) string {
+	if strings.Contains(endpoint, "://") {
+		if subscribe && !strings.HasPrefix(endpoint, "ws") {
+			log.Printf("Endpoint %s can not subscribe, it is polled\n", endpoint)
+		}
+		return endpoint
+	}
+	if subscribe {
+		return "ws://" + endpoint + "/"
+	}
+	return "http://" + endpoint + "/"
+}
+
+// parentEdge is an edge from the event to its parent found by the walk, port is the index of the parent
+type parentEdge struct {
+	event, parent *types.EventNode
+	port          int
+}
+
+// forgetHeads allows to capture the heads of an aborted loop again
+func forgetHeads(processedTop map[hash.Event]bool, top hash.Events) {
+	for _, h := range top {
+		delete(processedTop, h)
 	}
 }
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestEndpointURL(t *testing.T) {
+	for _, c := range []struct {
+		endpoint  string
+		subscribe bool
+		want      string
+	}{
+		{"node1:18545", false, "http://node1:18545/"},
+		{"node1:18546", true, "ws://node1:18546/"},
+		{"wss://node1/rpc", true, "wss://node1/rpc"},
+		{"http://node1:18545/", true, "http://node1:18545/"},
+	} {
+		if got := endpointURL(c.endpoint, c.subscribe); got != c.want {
+			t.Errorf("endpointURL(%q, %v) = %q, want %q", c.endpoint, c.subscribe, got, c.want)
+		}
+	}
+}
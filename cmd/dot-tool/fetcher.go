@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// prefetch loads the DAG below the already known nodes level by level.
+// Events missing in the store are split into batches fetched by a bounded
+// pool of workers, so the graph traversal afterwards runs on the nodes cache only.
+// Parents of the nodes beyond the level limit or below the window are not expanded.
+func prefetch(ctx context.Context, r eventSource, store *eventStore, dag *types.Dag, cfg *Config, nodes map[hash.Event]*types.EventNode, startLevel idx.Event) error {
+	expanded := make(map[hash.Event]bool)
+	frontier := make(hash.Events, 0, len(nodes))
+	for h := range nodes {
+		frontier = append(frontier, h)
+	}
+
+	for len(frontier) > 0 {
+		// collect parents which are not in the nodes cache yet
+		next := make(hash.Events, 0)
+		missing := make(hash.Events, 0)
+		queued := make(map[hash.Event]bool)
+		for _, h := range frontier {
+			if expanded[h] {
+				continue
+			}
+			expanded[h] = true
+
+			node := nodes[h]
+			if cfg.LvlLimit > 0 && int(startLevel-node.Seq()) > cfg.LvlLimit {
+				continue
+			}
+			// events below the lamport or frame bounds are stubs, their parents are not needed
+			if cfg.Window.Passed(node) {
+				continue
+			}
+			for _, parent := range node.Parents() {
+				if _, ok := nodes[parent]; ok || queued[parent] {
+					continue
+				}
+				queued[parent] = true
+				next = append(next, parent)
+				if !store.Has(parent) {
+					missing = append(missing, parent)
+				}
+			}
+		}
+
+		err := fetchLevel(ctx, r, store, missing, cfg.BatchSize, cfg.Workers)
+		if err != nil {
+			return err
+		}
+		for _, h := range next {
+			if nodes[h], err = fetchNode(ctx, r, store, dag, h); err != nil {
+				return err
+			}
+		}
+		frontier = next
+	}
+
+	return nil
+}
+
+// fetchNode returns the event of the DAG, an unknown event is taken from the store or requested from the node
+func fetchNode(ctx context.Context, r eventSource, store *eventStore, dag *types.Dag, h hash.Event) (*types.EventNode, error) {
+	if p := dag.Event(h); p != nil {
+		return p, nil
+	}
+	p, err := store.FetchNode(ctx, r, h)
+	if err != nil {
+		return nil, err
+	}
+	dag.Add(p)
+	return p, nil
+}
+
+// fetchLevel gets the events into the store in batches with at most workers concurrent calls.
+// The first failed batch cancels the others.
+func fetchLevel(ctx context.Context, r eventSource, store *eventStore, hh hash.Events, batchSize, workers int) error {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batches := make(chan hash.Events)
+	go func() {
+		defer close(batches)
+		for start := 0; start < len(hh); start += batchSize {
+			end := start + batchSize
+			if end > len(hh) {
+				end = len(hh)
+			}
+			select {
+			case batches <- hh[start:end]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, 0)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				raws, err := r.GetEvents(ctx, batch)
+				for i := 0; err == nil && i < len(raws); i++ {
+					_, err = store.Put(raws[i])
+				}
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fantom-foundation/go-opera/inter"
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/dag"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// newChainEvent returns the event of creator 1 with lamport time and frame equal to seq
+func newChainEvent(seq idx.Event, parents ...*types.EventNode) *types.EventNode {
+	e := &dag.MutableBaseEvent{}
+	e.SetEpoch(1)
+	e.SetCreator(1)
+	e.SetSeq(seq)
+	e.SetLamport(idx.Lamport(seq))
+	e.SetFrame(idx.Frame(seq))
+	hh := make(hash.Events, len(parents))
+	for i, p := range parents {
+		hh[i] = p.ID()
+	}
+	e.SetParents(hh)
+	return types.NewEventNode(&testEvent{BaseEvent: e.Build([24]byte{byte(seq)})})
+}
+
+// rawTestEvent encodes the event for unmarshalTestEvent
+func rawTestEvent(e *types.EventNode) json.RawMessage {
+	parents := make([]string, len(e.Parents()))
+	for i, p := range e.Parents() {
+		parents[i] = p.Hex()
+	}
+	raw, _ := json.Marshal(map[string]interface{}{
+		"id":      e.ID().Hex(),
+		"epoch":   e.Epoch(),
+		"seq":     e.Seq(),
+		"creator": e.Creator(),
+		"frame":   e.Frame(),
+		"lamport": e.Lamport(),
+		"parents": parents,
+	})
+	return raw
+}
+
+func unmarshalTestEvent(fields map[string]interface{}) inter.EventI {
+	num := func(name string) uint64 {
+		v, _ := fields[name].(float64)
+		return uint64(v)
+	}
+	e := &dag.MutableBaseEvent{}
+	e.SetEpoch(idx.Epoch(num("epoch")))
+	e.SetSeq(idx.Event(num("seq")))
+	e.SetCreator(idx.ValidatorID(num("creator")))
+	e.SetFrame(idx.Frame(num("frame")))
+	e.SetLamport(idx.Lamport(num("lamport")))
+	parents := make(hash.Events, 0)
+	for _, p := range fields["parents"].([]interface{}) {
+		parents = append(parents, hash.HexToEventHash(p.(string)))
+	}
+	e.SetParents(parents)
+	var rID [24]byte
+	copy(rID[:], hash.HexToEventHash(fields["id"].(string)).Bytes()[8:])
+	return &testEvent{BaseEvent: e.Build(rID)}
+}
+
+// useTestEvents makes the store decode the events of rawTestEvent
+func useTestEvents(t *testing.T) {
+	unmarshal := unmarshalEvent
+	unmarshalEvent = unmarshalTestEvent
+	t.Cleanup(func() {
+		unmarshalEvent = unmarshal
+	})
+}
+
+// testSource serves the events of the tests and records the requested ones
+type testSource struct {
+	mu        sync.Mutex
+	events    map[hash.Event]json.RawMessage
+	requested map[hash.Event]bool
+}
+
+func newTestSource(events ...*types.EventNode) *testSource {
+	s := &testSource{
+		events:    make(map[hash.Event]json.RawMessage),
+		requested: make(map[hash.Event]bool),
+	}
+	for _, e := range events {
+		s.events[e.ID()] = rawTestEvent(e)
+	}
+	return s
+}
+
+func (s *testSource) Now() time.Time { return time.Time{} }
+
+func (s *testSource) GetHeads(ctx context.Context, epoch *big.Int) (hash.Events, error) {
+	return nil, nil
+}
+
+func (s *testSource) GetEvent(ctx context.Context, h hash.Event) (json.RawMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requested[h] = true
+	return s.events[h], nil
+}
+
+func (s *testSource) GetEvents(ctx context.Context, hh hash.Events) ([]json.RawMessage, error) {
+	raws := make([]json.RawMessage, len(hh))
+	for i, h := range hh {
+		raws[i], _ = s.GetEvent(ctx, h)
+	}
+	return raws, nil
+}
+
+func (s *testSource) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return nil
+}
+
+func TestPrefetchWindow(t *testing.T) {
+	useTestEvents(t)
+
+	chain := []*types.EventNode{newChainEvent(1)}
+	for seq := idx.Event(2); seq <= 6; seq++ {
+		chain = append(chain, newChainEvent(seq, chain[len(chain)-1]))
+	}
+	src := newTestSource(chain...)
+	store, _ := openEventStore("")
+	cfg := &Config{BatchSize: 2, Workers: 2, Window: window{MinLamport: 4}}
+	head := chain[5]
+	nodes := map[hash.Event]*types.EventNode{head.ID(): head}
+
+	if err := prefetch(context.Background(), src, store, types.NewDag(), cfg, nodes, head.Seq()); err != nil {
+		t.Fatal(err)
+	}
+	// the event below the window is fetched as the stub of its child, its parents are not
+	for seq, e := range chain[:5] {
+		if want := seq >= 2; src.requested[e.ID()] != want {
+			t.Errorf("event %d requested: %t", seq+1, src.requested[e.ID()])
+		}
+		if _, ok := nodes[e.ID()]; ok != (seq >= 2) {
+			t.Errorf("event %d in the nodes: %t", seq+1, ok)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+var colorFork = dot.RGB(0xFF, 0x00, 0xFF)
+
+// markForks marks the forked events and the clusters of the cheaters
+func markForks(forks []types.Misbehaviour, nodes map[hash.Event]*types.EventNode, inGraph map[string]*dot.Node, subGraphs map[string]*dot.SubGraph) {
+	marked := make(map[string]bool)
+	for _, m := range forks {
+		for _, h := range m.Events {
+			p, ok := nodes[h]
+			if !ok || p.Creator() != m.Creator {
+				continue
+			}
+			if n, ok := inGraph[p.NodeName]; ok {
+				n.SetColor(colorFork)
+				n.SetPenWidth(3)
+			}
+			if sg, ok := subGraphs[p.NodeGroup]; ok && !marked[p.NodeGroup] {
+				checkAttrs("cluster "+p.NodeGroup,
+					sg.SetStyle(dot.StyleBold),
+					sg.SetColor(colorFork),
+					sg.SetLabel(sg.Get("label")+" (cheater)"))
+				marked[p.NodeGroup] = true
+			}
+		}
+	}
+}
+
+// flushForks writes the misbehaviour summary next to the graph files
+func flushForks(fileBase string, forks []types.Misbehaviour) error {
+	fl, err := os.Create(fileBase + ".forks.txt")
+	if err != nil {
+		return err
+	}
+	err = types.WriteForks(fl, forks)
+	if cerr := fl.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Kinds of the record entries
+const (
+	recordHeads = "heads"
+	recordEvent = "event"
+	recordCall  = "call"
+)
+
+// recordEntry is a line of the record file
+type recordEntry struct {
+	Kind   string          `json:"kind"`
+	Time   int64           `json:"time,omitempty"`
+	Heads  []hash.Hash     `json:"heads,omitempty"`
+	Event  json.RawMessage `json:"event,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Args   json.RawMessage `json:"args,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// recorder writes everything the capture loop gets from the node to a file:
+// the heads of every loop, every event once and the results of other calls.
+// The calls belong to the heads written before them, a loop which failed and
+// was repeated with the same heads records its calls again after the same heads.
+type recorder struct {
+	eventSource
+
+	mu        sync.Mutex
+	file      *os.File
+	w         *bufio.Writer
+	enc       *json.Encoder
+	written   map[hash.Event]bool
+	lastHeads hash.Events
+	now       time.Time
+}
+
+// newRecorder starts a record, events already in the store are written first
+func newRecorder(src eventSource, path string, store *eventStore) (*recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	r := &recorder{
+		eventSource: src,
+		file:        f,
+		w:           w,
+		enc:         json.NewEncoder(w),
+		written:     make(map[hash.Event]bool),
+	}
+
+	for _, h := range store.Hashes() {
+		raw, _ := store.Raw(h)
+		if err = r.writeEvent(h, raw); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *recorder) writeEvent(h hash.Event, raw json.RawMessage) error {
+	if r.written[h] {
+		return nil
+	}
+	r.written[h] = true
+	return r.enc.Encode(recordEntry{Kind: recordEvent, Event: raw})
+}
+
+func (r *recorder) writeEvents(raws []json.RawMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, raw := range raws {
+		e, _, err := decodeEvent(raw)
+		if err != nil {
+			return err
+		}
+		if err = r.writeEvent(e.ID(), raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *recorder) Now() time.Time {
+	return r.now
+}
+
+// GetHeads records the heads if they differ from the previous ones,
+// the events of the previous loop are flushed to the file before.
+func (r *recorder) GetHeads(ctx context.Context, epoch *big.Int) (hash.Events, error) {
+	top, err := r.eventSource.GetHeads(ctx, epoch)
+	if err != nil {
+		return nil, err
+	}
+	r.now = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if sameEvents(top, r.lastHeads) {
+		return top, nil
+	}
+	r.lastHeads = top
+
+	heads := make([]hash.Hash, len(top))
+	for i, h := range top {
+		heads[i] = hash.Hash(h)
+	}
+	if err = r.enc.Encode(recordEntry{Kind: recordHeads, Time: r.now.UnixNano(), Heads: heads}); err != nil {
+		return nil, err
+	}
+	return top, r.w.Flush()
+}
+
+func (r *recorder) GetEvent(ctx context.Context, h hash.Event) (json.RawMessage, error) {
+	raw, err := r.eventSource.GetEvent(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	return raw, r.writeEvents([]json.RawMessage{raw})
+}
+
+func (r *recorder) GetEvents(ctx context.Context, hh hash.Events) ([]json.RawMessage, error) {
+	raws, err := r.eventSource.GetEvents(ctx, hh)
+	if err != nil {
+		return nil, err
+	}
+	return raws, r.writeEvents(raws)
+}
+
+func (r *recorder) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	err := r.eventSource.CallContext(ctx, result, method, args...)
+	if err != nil {
+		return err
+	}
+	rawArgs, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	rawResult, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.enc.Encode(recordEntry{Kind: recordCall, Method: method, Args: rawArgs, Result: rawResult})
+}
+
+// SubscribeHeads subscribes to the recorded source, if it supports notifications
+func (r *recorder) SubscribeHeads(ctx context.Context) (<-chan struct{}, error) {
+	if n, ok := r.eventSource.(headsNotifier); ok {
+		return n.SubscribeHeads(ctx)
+	}
+	return nil, rpc.ErrNotificationsUnsupported
+}
+
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.w.Flush()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func sameEvents(a, b hash.Events) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// replaySource feeds a record back to the capture loop instead of a node
+type replaySource struct {
+	heads []recordEntry
+	calls map[string][]recordedCall
+	next  int
+	now   time.Time
+}
+
+// recordedCall is the answer of a call after the given count of heads,
+// it is the last answer recorded with these heads
+type recordedCall struct {
+	heads  int
+	result json.RawMessage
+}
+
+// openReplay reads the record, recorded events are put into the store
+func openReplay(path string, store *eventStore) (*replaySource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &replaySource{
+		calls: make(map[string][]recordedCall),
+	}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var entry recordEntry
+		err = dec.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch entry.Kind {
+		case recordHeads:
+			s.heads = append(s.heads, entry)
+		case recordEvent:
+			if _, err = store.Put(entry.Event); err != nil {
+				return nil, err
+			}
+		case recordCall:
+			key := callKey(entry.Method, entry.Args)
+			calls := s.calls[key]
+			if len(calls) > 0 && calls[len(calls)-1].heads == len(s.heads) {
+				// the call of a repeated loop
+				calls[len(calls)-1].result = entry.Result
+			} else {
+				s.calls[key] = append(calls, recordedCall{len(s.heads), entry.Result})
+			}
+		default:
+			return nil, fmt.Errorf("unknown record entry %q", entry.Kind)
+		}
+	}
+	return s, nil
+}
+
+func callKey(method string, args json.RawMessage) string {
+	return method + string(args)
+}
+
+func (s *replaySource) Now() time.Time {
+	return s.now
+}
+
+// GetHeads returns the recorded heads loop by loop and io.EOF at the end of the record
+func (s *replaySource) GetHeads(ctx context.Context, epoch *big.Int) (hash.Events, error) {
+	if s.next >= len(s.heads) {
+		return nil, io.EOF
+	}
+	entry := s.heads[s.next]
+	s.next++
+
+	s.now = time.Unix(0, entry.Time)
+	top := make(hash.Events, len(entry.Heads))
+	for i, h := range entry.Heads {
+		top[i] = hash.Event(h)
+	}
+	return top, nil
+}
+
+// GetEvent is called for the events which are not in the store, so not recorded
+func (s *replaySource) GetEvent(ctx context.Context, h hash.Event) (json.RawMessage, error) {
+	return nil, fmt.Errorf("event %s is not recorded", h.String())
+}
+
+func (s *replaySource) GetEvents(ctx context.Context, hh hash.Events) ([]json.RawMessage, error) {
+	return nil, fmt.Errorf("%d events are not recorded", len(hh))
+}
+
+// CallContext answers the call as it was answered after the current heads,
+// the last answer before them is repeated if the call was not made with these heads
+func (s *replaySource) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	rawArgs, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	calls := s.calls[callKey(method, rawArgs)]
+	i := sort.Search(len(calls), func(i int) bool {
+		return calls[i].heads > s.next
+	})
+	if i == 0 {
+		return fmt.Errorf("call %s%s is not recorded", method, rawArgs)
+	}
+	return json.Unmarshal(calls[i-1].result, result)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// loopSource answers the heads and the block numbers of the loops in turn
+type loopSource struct {
+	*testSource
+	heads   []hash.Events
+	numbers []uint64
+}
+
+func (s *loopSource) GetHeads(ctx context.Context, epoch *big.Int) (hash.Events, error) {
+	top := s.heads[0]
+	s.heads = s.heads[1:]
+	return top, nil
+}
+
+func (s *loopSource) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	*result.(*hexutil.Uint64) = hexutil.Uint64(s.numbers[0])
+	s.numbers = s.numbers[1:]
+	return nil
+}
+
+func TestRecordReplay(t *testing.T) {
+	useTestEvents(t)
+	ctx := context.Background()
+	e1 := newChainEvent(1)
+	e2 := newChainEvent(2, e1)
+	e3 := newChainEvent(3, e2)
+	path := filepath.Join(t.TempDir(), "dag.rec")
+
+	src := &loopSource{
+		testSource: newTestSource(e1, e2, e3),
+		// the 2nd loop fails after the call and it is repeated with the same heads
+		heads:   []hash.Events{{e1.ID()}, {e2.ID()}, {e2.ID()}, {e3.ID()}},
+		numbers: []uint64{10, 11, 12},
+	}
+	store, _ := openEventStore("")
+	rec, err := newRecorder(src, path, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockNumber := func(r eventSource) (uint64, error) {
+		var n hexutil.Uint64
+		err := r.CallContext(ctx, &n, "eth_blockNumber")
+		return uint64(n), err
+	}
+	for loop := 0; loop < 4; loop++ {
+		top, err := rec.GetHeads(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = store.Fetch(ctx, rec, top[0]); err != nil {
+			t.Fatal(err)
+		}
+		// the last loop makes no calls
+		if loop < 3 {
+			if _, err = blockNumber(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err = rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, _ := openEventStore("")
+	replay, err := openReplay(path, replayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []*types.EventNode{e1, e2, e3} {
+		if raw, ok := replayed.Raw(e.ID()); !ok || !bytes.Equal(raw, rawTestEvent(e)) {
+			t.Errorf("event %s is not replayed: %s", e.ID(), raw)
+		}
+	}
+	// the repeated heads are recorded once, with the answer of the repeated loop,
+	// the last answer is repeated for the heads without the call
+	for i, expected := range []struct {
+		head   *types.EventNode
+		number uint64
+	}{{e1, 10}, {e2, 12}, {e3, 12}} {
+		top, err := replay.GetHeads(ctx, nil)
+		if err != nil || len(top) != 1 || top[0] != expected.head.ID() {
+			t.Fatalf("heads %d: %v, %v", i, top, err)
+		}
+		if n, err := blockNumber(replay); err != nil || n != expected.number {
+			t.Errorf("heads %d: block %d instead of %d, %v", i, n, expected.number, err)
+		}
+	}
+	if _, err = replay.GetHeads(ctx, nil); !errors.Is(err, io.EOF) {
+		t.Errorf("'%v' is not the end of the record", err)
+	}
+}
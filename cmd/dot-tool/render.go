@@ -2,42 +2,172 @@ package main
 
 import (
 	"fmt"
+	"html/template"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/Fantom-foundation/lachesis-base/inter/idx"
 
 	"github.com/Fantom-foundation/dag2dot-tool/dot"
+	"github.com/Fantom-foundation/dag2dot-tool/types"
 )
 
-func flushToFile(cfg *Config, epoch idx.Epoch, g *dot.Graph) {
+// formatDot and formatSVG are written from the dot graph, the other formats by types.GraphWriters
+const (
+	formatDot = "dot"
+	formatSVG = "svg"
+)
+
+var outputFormats = []string{formatDot, formatSVG, "json", "graphml", "gexf"}
+
+func hasFormat(cfg *Config, format string) bool {
+	return indexOf(cfg.Formats, format) >= 0
+}
+
+func flushToFile(cfg *Config, epoch idx.Epoch, g *dot.Graph, data *types.GraphData) {
 	prefix := g.Name()
 	if cfg.OnlyEpoch {
 		prefix = fmt.Sprintf("DAG-EPOCH-%d", epoch)
 	}
 	fileBase := filepath.Join(cfg.OutPath, prefix)
 
-	// save *.dot
+	if hasFormat(cfg, formatDot) || hasFormat(cfg, formatSVG) {
+		fileDot := flushDot(fileBase, g, cfg.Gzip)
+
+		// render *.png
+		if cfg.RenderFile && hasFormat(cfg, formatDot) {
+			render(fileDot, "png", fileBase+".png")
+		}
+		// render *.svg and the index of them
+		if hasFormat(cfg, formatSVG) {
+			render(fileDot, "svg", fileBase+".svg")
+			writeIndex(cfg.OutPath)
+		}
+	}
+
+	for _, format := range cfg.Formats {
+		if w, ok := types.GraphWriters[format]; ok {
+			flushData(w, fileBase, g.Name(), data)
+		}
+	}
+
+	if forks := data.Forks(); len(forks) > 0 {
+		if err := flushForks(fileBase, forks); err != nil {
+			log.Panicf("Can not write forks summary '%s': %s\n", fileBase, err)
+		}
+	}
+}
+
+func flushDot(fileBase string, g *dot.Graph, compress bool) string {
+	// save *.dot or *.dot.gz, the graph is streamed to the file
 	fileDot := fileBase + ".dot"
-	fl, err := os.Create(fileDot)
+	if compress {
+		fileDot += ".gz"
+	}
+	if err := g.WriteFile(fileDot, compress); err != nil {
+		log.Panicf("Can not write data to file '%s': %s\n", fileDot, err)
+	}
+	return fileDot
+}
+
+func render(fileDot, format, fileOut string) {
+	// the dot program reads compressed files from stdin
+	fl, err := os.Open(fileDot)
 	if err != nil {
-		log.Panicf("Can not create file '%s': %s\n", fileDot, err)
+		log.Panicf("Can not open file '%s': %s\n", fileDot, err)
 	}
-	_, err = fl.WriteString(g.String())
+	defer fl.Close()
+	in, err := dot.OpenReader(fl)
 	if err != nil {
-		log.Panicf("Can not write data to file '%s': %s\n", fileDot, err)
+		log.Panicf("Can not read file '%s': %s\n", fileDot, err)
+	}
+
+	cmd := exec.Command("dot", "-T"+format, "-o", fileOut)
+	cmd.Stdin = in
+	if _, err = cmd.Output(); err != nil {
+		log.Panicf("Can not write img to file '%s': %s\n", fileOut, err)
+	}
+}
+
+func flushData(w types.GraphWriter, fileBase, name string, data *types.GraphData) {
+	file := fileBase + "." + w.Ext()
+	fl, err := os.Create(file)
+	if err != nil {
+		log.Panicf("Can not create file '%s': %s\n", file, err)
+	}
+	err = w.Write(fl, name, data)
+	if err != nil {
+		log.Panicf("Can not write data to file '%s': %s\n", file, err)
 	}
 	_ = fl.Close()
+}
 
-	// render *.png
-	if !cfg.RenderFile {
-		return
+// setEventLinks makes the node of an SVG image clickable with a tooltip of the event details
+func setEventLinks(n *dot.Node, p *types.EventNode) {
+	id := p.ID().Hex()
+	checkAttrs("event "+p.ID().String(),
+		n.Set("id", id),
+		n.Set("URL", "#"+id),
+		n.Set("tooltip", p.Tooltip()))
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>DAG snapshots</title>
+<style>
+body { display: flex; margin: 0; font-family: sans-serif; }
+nav { min-width: 16em; max-height: 100vh; overflow-y: auto; padding: 0.5em; }
+nav a { display: block; }
+iframe { flex-grow: 1; height: 100vh; border: none; }
+</style>
+</head>
+<body>
+<nav>
+{{range .}}<a href="{{.}}" target="view">{{.}}</a>
+{{end}}</nav>
+<iframe name="view"{{if .}} src="{{index . 0}}"{{end}}></iframe>
+</body>
+</html>
+`))
+
+// writeIndex writes index.html to click through the SVG snapshots of the directory, the latest first
+func writeIndex(dir string) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.svg"))
+	if err != nil {
+		log.Panicf("Can not list SVG files in '%s': %s\n", dir, err)
+	}
+	names := make([]string, 0, len(files))
+	modTimes := make(map[string]int64, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(file)
+		names = append(names, name)
+		modTimes[name] = info.ModTime().UnixNano()
 	}
-	filePng := fileBase + ".png"
-	_, err = exec.Command("dot", "-Tpng", fileDot, "-o", filePng).Output()
+	sort.Slice(names, func(i, j int) bool {
+		if modTimes[names[i]] != modTimes[names[j]] {
+			return modTimes[names[i]] > modTimes[names[j]]
+		}
+		return strings.Compare(names[i], names[j]) > 0
+	})
+
+	fileIndex := filepath.Join(dir, "index.html")
+	fl, err := os.Create(fileIndex)
+	if err != nil {
+		log.Panicf("Can not create file '%s': %s\n", fileIndex, err)
+	}
+	err = indexTemplate.Execute(fl, names)
 	if err != nil {
-		log.Panicf("Can not write img to file '%s': %s\n", filePng, err)
+		log.Panicf("Can not write data to file '%s': %s\n", fileIndex, err)
 	}
+	_ = fl.Close()
 }
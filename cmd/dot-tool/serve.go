@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+)
+
+//go:embed viewer.html
+var viewerPage []byte
+
+//go:embed assets
+var assetsFS embed.FS // viewer scripts vendored by bin/fetch-viewer-assets.sh
+
+// viewerAssets are the viewer scripts and their pinned CDN copies, used if they are not vendored
+var viewerAssets = map[string]string{
+	"d3.min.js":          "https://unpkg.com/d3@7.8.5/dist/d3.min.js",
+	"graphviz.umd.js":    "https://unpkg.com/@hpcc-js/wasm@2.13.0/dist/graphviz.umd.js",
+	"d3-graphviz.min.js": "https://unpkg.com/d3-graphviz@5.1.0/build/d3-graphviz.min.js",
+}
+
+// snapshotInfo describes a snapshot kept by the viewer
+type snapshotInfo struct {
+	ID     int    `json:"id"`
+	Source string `json:"source"`
+	Name   string `json:"name"`
+	Time   int64  `json:"time"`
+}
+
+type snapshot struct {
+	snapshotInfo
+	dot string
+}
+
+// snapshotEvent is a Server-Sent Event of a snapshot,
+// the snapshots before the oldest one are dropped by the server
+type snapshotEvent struct {
+	Snapshot snapshotInfo `json:"snapshot"`
+	Oldest   int          `json:"oldest"`
+}
+
+// viewer is an HTTP server which keeps the latest snapshots of the capture loops
+// and streams new ones to the browser page over Server-Sent Events.
+type viewer struct {
+	mu      sync.Mutex
+	limit   int
+	nextID  int
+	history []snapshot
+	clients map[chan snapshotEvent]bool
+}
+
+func newViewer(limit int) *viewer {
+	return &viewer{
+		limit:   limit,
+		clients: make(map[chan snapshotEvent]bool),
+	}
+}
+
+// publisher returns the function to publish the graphs of the source
+func (v *viewer) publisher(source string) func(g *dot.Graph) {
+	return func(g *dot.Graph) {
+		v.publish(source, g)
+	}
+}
+
+func (v *viewer) publish(source string, g *dot.Graph) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s := snapshot{
+		snapshotInfo: snapshotInfo{
+			ID:     v.nextID,
+			Source: source,
+			Name:   g.Name(),
+			Time:   time.Now().UnixNano(),
+		},
+		dot: g.String(),
+	}
+	v.nextID++
+	v.history = append(v.history, s)
+	if v.limit > 0 && len(v.history) > v.limit {
+		v.history = v.history[len(v.history)-v.limit:]
+	}
+
+	for ch := range v.clients {
+		select {
+		case ch <- snapshotEvent{s.snapshotInfo, v.history[0].ID}:
+		default:
+			// slow client, it gets the history again on reconnect
+			delete(v.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+func (v *viewer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", v.serveIndex)
+	mux.HandleFunc("/snapshots", v.serveList)
+	mux.HandleFunc("/snapshots/", v.serveSnapshot)
+	mux.HandleFunc("/events", v.serveEvents)
+	mux.HandleFunc("/assets/", serveAsset)
+	return mux
+}
+
+func (v *viewer) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(viewerPage)
+}
+
+// serveAsset serves a vendored viewer script or redirects to its CDN copy
+func serveAsset(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/assets/")
+	url, ok := viewerAssets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := assetsFS.ReadFile("assets/" + name)
+	if err != nil {
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/javascript")
+	_, _ = w.Write(data)
+}
+
+// serveList returns the kept snapshots, the oldest first
+func (v *viewer) serveList(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	list := make([]snapshotInfo, 0, len(v.history))
+	for _, s := range v.history {
+		list = append(list, s.snapshotInfo)
+	}
+	v.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// serveSnapshot returns the DOT text of a snapshot
+func (v *viewer) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/snapshots/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	v.mu.Lock()
+	var text string
+	found := false
+	for _, s := range v.history {
+		if s.ID == id {
+			text, found = s.dot, true
+			break
+		}
+	}
+	v.mu.Unlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	_, _ = w.Write([]byte(text))
+}
+
+// serveEvents streams the kept snapshots and then the new ones as Server-Sent Events.
+// The client is subscribed together with the copy of the history, so no snapshot is lost in between.
+func (v *viewer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan snapshotEvent, 16)
+	v.mu.Lock()
+	v.clients[ch] = true
+	history := make([]snapshotEvent, len(v.history))
+	for i, s := range v.history {
+		history[i] = snapshotEvent{s.snapshotInfo, v.history[0].ID}
+	}
+	v.mu.Unlock()
+	defer func() {
+		v.mu.Lock()
+		delete(v.clients, ch)
+		v.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, e := range history {
+		writeSnapshotEvent(w, e)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeSnapshotEvent(w, e)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSnapshotEvent(w http.ResponseWriter, e snapshotEvent) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", data)
+}
+
+// listen serves the viewer until ctx is done
+func (v *viewer) listen(ctx context.Context, addr string) {
+	srv := &http.Server{Addr: addr, Handler: v.handler()}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+
+	log.Printf("Viewer at http://%s/\n", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Panicf("Can not serve viewer: %s\n", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+)
+
+func TestViewerEvents(t *testing.T) {
+	v := newViewer(2)
+	for _, name := range []string{"G0", "G1", "G2"} {
+		v.publish("", dot.NewGraph(name))
+	}
+	srv := httptest.NewServer(v.handler())
+	defer srv.Close()
+
+	// the dropped snapshot is gone
+	resp, err := http.Get(srv.URL + "/snapshots/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("dropped snapshot: %s", resp.Status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() snapshotEvent {
+		for lines.Scan() {
+			if data := strings.TrimPrefix(lines.Text(), "data: "); data != lines.Text() {
+				var e snapshotEvent
+				if err := json.Unmarshal([]byte(data), &e); err != nil {
+					t.Fatal(err)
+				}
+				return e
+			}
+		}
+		t.Fatal("stream closed")
+		return snapshotEvent{}
+	}
+
+	// the kept history is replayed on connect, then the new snapshots follow
+	for _, want := range []snapshotEvent{{snapshotInfo{ID: 1, Name: "G1"}, 1}, {snapshotInfo{ID: 2, Name: "G2"}, 1}} {
+		if e := next(); e.Snapshot.ID != want.Snapshot.ID || e.Snapshot.Name != want.Snapshot.Name || e.Oldest != want.Oldest {
+			t.Errorf("%+v != %+v", e, want)
+		}
+	}
+	v.publish("", dot.NewGraph("G3"))
+	if e := next(); e.Snapshot.ID != 3 || e.Oldest != 2 {
+		t.Errorf("new snapshot %+v", e)
+	}
+}
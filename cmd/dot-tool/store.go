@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Fantom-foundation/go-opera/ethapi"
+	"github.com/Fantom-foundation/go-opera/inter"
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+	"github.com/ethereum/go-ethereum"
+
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// eventStore keeps fetched events across capture loops.
+// Events never change once created, so every event is requested only once.
+// Optionally the events are appended to a file as the raw JSON returned by
+// dag_getEvent, one event per line, and loaded back on the next start.
+// The file keeps the same events as the memory: it is rewritten when the store
+// is pruned or when duplicates are loaded, so every event is written once.
+type eventStore struct {
+	mu     sync.RWMutex
+	events map[hash.Event]storedEvent
+
+	path string
+	file *os.File
+	w    *bufio.Writer
+	buf  bytes.Buffer
+}
+
+type storedEvent struct {
+	event inter.EventI
+	raw   json.RawMessage
+	txs   int
+}
+
+// openEventStore creates a store, path is the cache file or "" to keep events in memory only
+func openEventStore(path string) (*eventStore, error) {
+	s := &eventStore{
+		events: make(map[hash.Event]storedEvent),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	dups, err := s.load(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	s.path = path
+	s.file = f
+	s.w = bufio.NewWriter(f)
+	if dups > 0 {
+		log.Printf("Drop %d duplicated events from the events cache\n", dups)
+		if err = s.compact(); err != nil {
+			_ = s.file.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// load reads the cache file and positions it for appending, it returns count of the duplicated records
+func (s *eventStore) load(f *os.File) (dups int, err error) {
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("Drop incomplete record at the end of the events cache\n")
+			}
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		raw := json.RawMessage(line[:len(line)-1])
+		e, txs, err := decodeEvent(raw)
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := s.events[e.ID()]; ok {
+			dups++
+		} else {
+			s.events[e.ID()] = storedEvent{e, raw, txs}
+		}
+		offset += int64(len(line))
+	}
+	log.Printf("Loaded %d events from cache\n", len(s.events))
+
+	if err = f.Truncate(offset); err != nil {
+		return 0, err
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	return dups, err
+}
+
+// compact rewrites the cache file with the known events only.
+// The new file replaces the old one only if it is written completely.
+func (s *eventStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, e := range s.events {
+		if err = s.write(w, e.raw); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	// the buffered events are in the new file already
+	_ = s.file.Close()
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		s.file, s.w = nil, nil
+		return err
+	}
+	s.w = bufio.NewWriter(s.file)
+	return nil
+}
+
+// write writes the raw event as one line
+func (s *eventStore) write(w io.Writer, raw json.RawMessage) error {
+	err := json.Compact(&s.buf, raw)
+	if err == nil {
+		s.buf.WriteByte('\n')
+		_, err = s.buf.WriteTo(w)
+	}
+	s.buf.Reset()
+	return err
+}
+
+// unmarshalEvent decodes the fields of dag_getEvent, the tests replace it
+var unmarshalEvent = ethapi.RPCUnmarshalEvent
+
+// decodeEvent returns the event and count of its transactions, -1 if the payload is not fetched
+func decodeEvent(raw []byte) (inter.EventI, int, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, 0, err
+	}
+	if len(fields) == 0 {
+		return nil, 0, ethereum.NotFound
+	}
+	txs := -1
+	if list, ok := fields["transactions"].([]interface{}); ok {
+		txs = len(list)
+	}
+	return unmarshalEvent(fields), txs, nil
+}
+
+// Get returns a known event
+func (s *eventStore) Get(h hash.Event) (inter.EventI, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.events[h]
+	return e.event, ok
+}
+
+// Node returns a known event as a graph node
+func (s *eventStore) Node(h hash.Event) (*types.EventNode, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.events[h]
+	if !ok {
+		return nil, false
+	}
+	n := types.NewEventNode(e.event)
+	n.TxCount = e.txs
+	return n, true
+}
+
+// Raw returns a known event as raw JSON
+func (s *eventStore) Raw(h hash.Event) (json.RawMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.events[h]
+	return e.raw, ok
+}
+
+// Hashes returns all known events
+func (s *eventStore) Hashes() hash.Events {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hh := make(hash.Events, 0, len(s.events))
+	for h := range s.events {
+		hh = append(hh, h)
+	}
+	return hh
+}
+
+// Has reports whether the event is known
+func (s *eventStore) Has(h hash.Event) bool {
+	_, ok := s.Get(h)
+	return ok
+}
+
+// Put decodes and saves the raw event
+func (s *eventStore) Put(raw json.RawMessage) (inter.EventI, error) {
+	e, txs, err := decodeEvent(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if known, ok := s.events[e.ID()]; ok {
+		return known.event, nil
+	}
+	s.events[e.ID()] = storedEvent{e, raw, txs}
+
+	if s.w != nil {
+		if err = s.write(s.w, raw); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Fetch returns the event from the store or requests it from the node
+func (s *eventStore) Fetch(ctx context.Context, r eventSource, h hash.Event) (inter.EventI, error) {
+	if e, ok := s.Get(h); ok {
+		return e, nil
+	}
+	raw, err := r.GetEvent(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	return s.Put(raw)
+}
+
+// FetchNode returns the event as a graph node, the event is requested from the node if unknown
+func (s *eventStore) FetchNode(ctx context.Context, r eventSource, h hash.Event) (*types.EventNode, error) {
+	if _, err := s.Fetch(ctx, r, h); err != nil {
+		return nil, err
+	}
+	n, _ := s.Node(h)
+	return n, nil
+}
+
+// Prune forgets the events of epochs before the given one and drops them from the cache file
+func (s *eventStore) Prune(before idx.Epoch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pruned := 0
+	for h := range s.events {
+		if h.Epoch() < before {
+			delete(s.events, h)
+			pruned++
+		}
+	}
+	if pruned > 0 && s.file != nil {
+		if err := s.compact(); err != nil {
+			log.Printf("Can not compact events cache: %s\n", err)
+		}
+	}
+}
+
+// Flush writes the buffered events to the cache file
+func (s *eventStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Flush()
+}
+
+func (s *eventStore) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.Flush()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/dag"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// newEpochEvent returns the first event of the creator in the epoch
+func newEpochEvent(epoch idx.Epoch, creator idx.ValidatorID) *types.EventNode {
+	e := &dag.MutableBaseEvent{}
+	e.SetEpoch(epoch)
+	e.SetCreator(creator)
+	e.SetSeq(1)
+	e.SetLamport(1)
+	e.SetFrame(1)
+	e.SetParents(hash.Events{})
+	return types.NewEventNode(&testEvent{BaseEvent: e.Build([24]byte{byte(epoch), byte(creator)})})
+}
+
+// writeCache writes the lines of the cache file
+func writeCache(t *testing.T, path string, lines ...[]byte) {
+	t.Helper()
+	if err := os.WriteFile(path, bytes.Join(lines, nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cacheLines returns the count of the complete lines of the cache file
+func cacheLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func line(e *types.EventNode) []byte {
+	return append(rawTestEvent(e), '\n')
+}
+
+// checkStore reopens the cache file and checks its events
+func checkStore(t *testing.T, path string, expected ...*types.EventNode) {
+	t.Helper()
+	s, err := openEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if len(s.Hashes()) != len(expected) {
+		t.Errorf("%d events are loaded instead of %d", len(s.Hashes()), len(expected))
+	}
+	for _, e := range expected {
+		if raw, ok := s.Raw(e.ID()); !ok || !bytes.Equal(raw, rawTestEvent(e)) {
+			t.Errorf("event %s is not loaded: %s", e.ID(), raw)
+		}
+	}
+	if n := cacheLines(t, path); n != len(expected) {
+		t.Errorf("%d lines in the cache instead of %d", n, len(expected))
+	}
+}
+
+func TestEventStoreReload(t *testing.T) {
+	useTestEvents(t)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	s, err := openEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := newEpochEvent(1, 1), newEpochEvent(1, 2)
+	for _, e := range []*types.EventNode{a, b, a} {
+		if _, err = s.Put(rawTestEvent(e)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := cacheLines(t, path); n != 2 {
+		t.Errorf("%d lines are flushed instead of 2", n)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkStore(t, path, a, b)
+}
+
+func TestEventStoreTruncated(t *testing.T) {
+	useTestEvents(t)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	a, b, c := newEpochEvent(1, 1), newEpochEvent(1, 2), newEpochEvent(1, 3)
+	writeCache(t, path, line(a), line(b), rawTestEvent(c)[:10])
+
+	s, err := openEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Has(c.ID()) || len(s.Hashes()) != 2 {
+		t.Errorf("bad events are loaded: %v", s.Hashes())
+	}
+	// the incomplete record is dropped, the next ones are appended after the complete ones
+	if _, err = s.Put(rawTestEvent(c)); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkStore(t, path, a, b, c)
+}
+
+func TestEventStoreDuplicates(t *testing.T) {
+	useTestEvents(t)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	a, b := newEpochEvent(1, 1), newEpochEvent(1, 2)
+	writeCache(t, path, line(a), line(b), line(a), line(a))
+
+	s, err := openEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkStore(t, path, a, b)
+}
+
+func TestEventStorePrune(t *testing.T) {
+	useTestEvents(t)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	a, b, c := newEpochEvent(1, 1), newEpochEvent(2, 1), newEpochEvent(3, 1)
+	writeCache(t, path, line(a), line(b))
+
+	s, err := openEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the buffered event is kept by the rewritten file
+	if _, err = s.Put(rawTestEvent(c)); err != nil {
+		t.Fatal(err)
+	}
+	s.Prune(2)
+	if s.Has(a.ID()) || !s.Has(b.ID()) || !s.Has(c.ID()) {
+		t.Errorf("bad events are kept: %v", s.Hashes())
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkStore(t, path, b, c)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+var colorPartiallyKnown = dot.RGB(0xFF, 0xDD, 0xDD)
+
+// union combines the latest snapshots of all the nodes into a single DAG
+// where every event is marked with the nodes which knew it.
+// It is rendered once per round of the captures, not on every snapshot.
+type union struct {
+	mu     sync.Mutex
+	cfg    Config
+	store  *eventStore
+	known  []map[hash.Event]*types.EventNode
+	epochs []idx.Epoch
+	// pending marks the nodes with a snapshot since the last rendering
+	pending []bool
+	// publish, if set, gets every union graph
+	publish func(g *dot.Graph)
+}
+
+func newUnion(cfg Config, store *eventStore, count int) *union {
+	// the union graph is written to the root output directory by its name
+	cfg.OnlyEpoch = false
+	return &union{
+		cfg:     cfg,
+		store:   store,
+		known:   make([]map[hash.Event]*types.EventNode, count),
+		epochs:  make([]idx.Epoch, count),
+		pending: make([]bool, count),
+	}
+}
+
+// snapshot returns the snapshot callback of the i-th node
+func (u *union) snapshot(i int) func(epoch idx.Epoch, nodes map[hash.Event]*types.EventNode) {
+	return func(epoch idx.Epoch, nodes map[hash.Event]*types.EventNode) {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		// the capture changes its events in the next loops, the union keeps their copies
+		known := make(map[hash.Event]*types.EventNode, len(nodes))
+		for h, n := range nodes {
+			cp := *n
+			known[h] = &cp
+		}
+		u.known[i] = known
+		u.epochs[i] = epoch
+
+		// events of the epochs passed by all the nodes are not needed anymore
+		minEpoch := epoch
+		for _, e := range u.epochs {
+			if e < minEpoch {
+				minEpoch = e
+			}
+		}
+		u.store.Prune(minEpoch)
+
+		if !u.round(i) {
+			return
+		}
+		g, data := u.graph(epoch)
+		if u.cfg.OutPath != "" {
+			flushToFile(&u.cfg, epoch, g, data)
+		}
+		if u.publish != nil {
+			u.publish(g)
+		}
+	}
+}
+
+// round marks the snapshot of the i-th node, it reports whether the round is over:
+// every node sent its snapshot, or the i-th node sent the next one before the slow or stuck nodes
+func (u *union) round(i int) bool {
+	if !u.pending[i] {
+		u.pending[i] = true
+		for _, p := range u.pending {
+			if !p {
+				return false
+			}
+		}
+	}
+	for j := range u.pending {
+		u.pending[j] = false
+	}
+	return true
+}
+
+// graph builds the union DAG of the epoch
+func (u *union) graph(epoch idx.Epoch) (*dot.Graph, *types.GraphData) {
+	events := make(map[hash.Event]*types.EventNode)
+	for _, nodes := range u.known {
+		for h, n := range nodes {
+			if h.Epoch() == epoch {
+				events[h] = n
+			}
+		}
+	}
+	knownBy, partial := u.knowledge(epoch, events)
+
+	g := dot.NewGraph(fmt.Sprintf("UNION-EPOCH-%d", epoch))
+	data := &types.GraphData{}
+	checkAttrs("graph "+g.Name(),
+		g.Set("clusterrank", "local"),
+		g.Set("compound", "true"),
+		g.Set("newrank", "true"),
+		g.Set("ranksep", "0.05"))
+
+	hashes := make(hash.Events, 0, len(events))
+	for h := range events {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		a, b := events[hashes[i]], events[hashes[j]]
+		if a.Creator() != b.Creator() {
+			return a.Creator() < b.Creator()
+		}
+		return a.Seq() < b.Seq()
+	})
+
+	subGraphs := make(map[string]*dot.SubGraph)
+	groups := make([]string, 0)
+	inGraph := make(map[hash.Event]*dot.Node)
+	for _, h := range hashes {
+		p := events[h]
+		n := dot.NewNode(p.NodeName)
+		label := p.Label
+		checkAttrs("event "+p.ID().String(), n.Set("comment", p.Comment()))
+		data.AddEventNode(n, p)
+		if hasFormat(&u.cfg, formatSVG) {
+			setEventLinks(n, p)
+		}
+		if partial[h] {
+			n.SetStyle(dot.StyleFilled)
+			n.SetFillColor(colorPartiallyKnown)
+			label += "\nknown by: " + strings.Join(knownBy[h], ",")
+		}
+		checkAttrs("event "+p.ID().String(), n.SetLabel(label))
+		inGraph[h] = n
+
+		sg, ok := subGraphs[p.NodeGroup]
+		if !ok {
+			sg = dot.NewSubgraph("cluster" + strconv.Itoa(len(subGraphs)))
+			checkAttrs("cluster "+p.NodeGroup, sg.SetStyle(dot.StyleDotted), sg.SetLabel(p.NodeGroup))
+			subGraphs[p.NodeGroup] = sg
+			groups = append(groups, p.NodeGroup)
+		}
+		sg.AddNode(n)
+	}
+	for _, group := range groups {
+		g.AddSubgraph(subGraphs[group])
+	}
+
+	for _, h := range hashes {
+		for _, parent := range events[h].Parents() {
+			if n, ok := inGraph[parent]; ok {
+				e := dot.NewEdge(inGraph[h], n)
+				data.AddEdge(e)
+				g.AddEdge(e)
+			}
+		}
+	}
+
+	log.Printf("Union of epoch %d: %d events\n", epoch, len(events))
+	return g, data
+}
+
+// knowledge returns the nodes which knew the events of the union and the events some nodes did not know.
+// A node knew the events it fetched and their ancestors, though they were cut from its snapshot
+// by -limit or the window. The events fetched below the lowest lamport time of a node are
+// beyond its cut, so the node is not counted as missing them.
+func (u *union) knowledge(epoch idx.Epoch, events map[hash.Event]*types.EventNode) (knownBy map[hash.Event][]string, partial map[hash.Event]bool) {
+	knownBy = make(map[hash.Event][]string)
+	partial = make(map[hash.Event]bool)
+	for i, nodes := range u.known {
+		known := make(map[hash.Event]bool)
+		var cut idx.Lamport
+		stack := make(hash.Events, 0)
+		for h, n := range nodes {
+			if h.Epoch() != epoch {
+				continue
+			}
+			if cut == 0 || n.Lamport() < cut {
+				cut = n.Lamport()
+			}
+			stack = append(stack, h)
+		}
+		for len(stack) > 0 {
+			h := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if known[h] {
+				continue
+			}
+			known[h] = true
+			if e, ok := events[h]; ok {
+				stack = append(stack, e.Parents()...)
+			}
+		}
+
+		for h, e := range events {
+			if known[h] {
+				knownBy[h] = append(knownBy[h], strconv.Itoa(i+1))
+			} else if e.Lamport() >= cut {
+				partial[h] = true
+			}
+		}
+	}
+	return knownBy, partial
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/dag"
+
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+func TestUnionKnowledge(t *testing.T) {
+	e1 := newChainEvent(1)
+	e2 := newChainEvent(2, e1)
+	e3 := newChainEvent(3, e2)
+	x := newChainEvent(4, e2)
+	// y is not an ancestor of e3 and it is below the cut of the 2nd node
+	side := &dag.MutableBaseEvent{}
+	side.SetEpoch(1)
+	side.SetCreator(2)
+	side.SetSeq(1)
+	side.SetLamport(2)
+	side.SetParents(hash.Events{e1.ID()})
+	y := types.NewEventNode(&testEvent{BaseEvent: side.Build([24]byte{2, 2})})
+	snapshot := func(events ...*types.EventNode) map[hash.Event]*types.EventNode {
+		nodes := make(map[hash.Event]*types.EventNode)
+		for _, e := range events {
+			nodes[e.ID()] = e
+		}
+		return nodes
+	}
+
+	u := newUnion(Config{}, nil, 2)
+	// the 2nd node is cut by -limit
+	u.known = []map[hash.Event]*types.EventNode{
+		snapshot(e1, e2, e3, x, y),
+		snapshot(e3),
+	}
+	events := snapshot(e1, e2, e3, x, y)
+	knownBy, partial := u.knowledge(1, events)
+
+	expected := map[*types.EventNode]string{e1: "1,2", e2: "1,2", e3: "1,2", x: "1", y: "1"}
+	for e, nodes := range expected {
+		if got := strings.Join(knownBy[e.ID()], ","); got != nodes {
+			t.Errorf("event %s is known by %s instead of %s", e.ID(), got, nodes)
+		}
+	}
+	// the ancestors of the fetched events and the events below the cut are not missing
+	for _, e := range []*types.EventNode{e1, e2, e3, y} {
+		if partial[e.ID()] {
+			t.Errorf("event %s is partially known", e.ID())
+		}
+	}
+	if !partial[x.ID()] {
+		t.Error("event missed by the 2nd node is known")
+	}
+}
+
+func TestUnionRound(t *testing.T) {
+	u := newUnion(Config{}, nil, 3)
+	// the round is over when every node sent a snapshot or a node sent the next one
+	for i, expected := range []bool{false, false, true, false, true, false} {
+		node := []int{0, 1, 2, 0, 0, 2}[i]
+		if u.round(node) != expected {
+			t.Errorf("snapshot %d of node %d: round over is not %t", i, node, expected)
+		}
+	}
+}
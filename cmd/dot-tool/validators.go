@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// sfcAddress is the address of the SFC contract keeping the validators
+var sfcAddress = common.HexToAddress("0xFC00FACE00000000000000000000000000000000")
+
+// sfcABI is the part of the SFC interface used to get the validators
+const sfcABI = `[
+{"type":"function","name":"getEpochValidatorIDs","stateMutability":"view",
+ "inputs":[{"name":"epoch","type":"uint256"}],
+ "outputs":[{"name":"","type":"uint256[]"}]},
+{"type":"function","name":"getEpochReceivedStake","stateMutability":"view",
+ "inputs":[{"name":"epoch","type":"uint256"},{"name":"validatorID","type":"uint256"}],
+ "outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"getValidator","stateMutability":"view",
+ "inputs":[{"name":"","type":"uint256"}],
+ "outputs":[{"name":"status","type":"uint256"},{"name":"deactivatedTime","type":"uint256"},
+  {"name":"deactivatedEpoch","type":"uint256"},{"name":"receivedStake","type":"uint256"},
+  {"name":"createdEpoch","type":"uint256"},{"name":"createdTime","type":"uint256"},
+  {"name":"auth","type":"address"}]}
+]`
+
+var sfc = mustParseABI(sfcABI)
+
+func mustParseABI(s string) abi.ABI {
+	res, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// validator is a validator of an epoch
+type validator struct {
+	ID      idx.ValidatorID
+	Stake   *big.Int
+	Address common.Address
+}
+
+// validatorSet is the validators of an epoch with their stakes
+type validatorSet struct {
+	Epoch      idx.Epoch
+	Validators map[idx.ValidatorID]*validator
+	Total      *big.Int
+}
+
+// Weight returns the stake share in percents
+func (vs *validatorSet) Weight(stake *big.Int) float64 {
+	if vs.Total.Sign() == 0 {
+		return 0
+	}
+	w, _ := new(big.Rat).SetFrac(new(big.Int).Mul(stake, big.NewInt(100)), vs.Total).Float64()
+	return w
+}
+
+// validatorTracker asks the SFC contract for the validators of every epoch.
+// The sets are cached for the whole capture and never pruned, a set is small
+// and the capture shows a few epochs only.
+type validatorTracker struct {
+	client eventSource
+	epochs map[idx.Epoch]*validatorSet
+}
+
+func newValidatorTracker(client eventSource) *validatorTracker {
+	return &validatorTracker{
+		client: client,
+		epochs: make(map[idx.Epoch]*validatorSet),
+	}
+}
+
+// call calls the view method of the SFC and unpacks its outputs.
+// It calls the latest state: the SFC keeps the validator IDs and the stakes of
+// the past epochs, but the validator addresses are the current ones, and a node
+// without the historic state can only answer for the latest block anyway.
+func (t *validatorTracker) call(ctx context.Context, method string, args ...interface{}) ([]interface{}, error) {
+	input, err := sfc.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	msg := map[string]interface{}{
+		"to":   sfcAddress,
+		"data": hexutil.Bytes(input),
+	}
+	var output hexutil.Bytes
+	if err = t.client.CallContext(ctx, &output, "eth_call", msg, "latest"); err != nil {
+		return nil, err
+	}
+	return sfc.Unpack(method, output)
+}
+
+// Validators returns the validators of the epoch, sets of the epochs are asked once
+func (t *validatorTracker) Validators(ctx context.Context, epoch idx.Epoch) (*validatorSet, error) {
+	if vs, ok := t.epochs[epoch]; ok {
+		return vs, nil
+	}
+
+	bigEpoch := new(big.Int).SetUint64(uint64(epoch))
+	out, err := t.call(ctx, "getEpochValidatorIDs", bigEpoch)
+	if err != nil {
+		return nil, fmt.Errorf("getEpochValidatorIDs: %w", err)
+	}
+
+	vs := &validatorSet{
+		Epoch:      epoch,
+		Validators: make(map[idx.ValidatorID]*validator),
+		Total:      new(big.Int),
+	}
+	ids, ok := out[0].([]*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("getEpochValidatorIDs: unexpected output %T", out[0])
+	}
+	for _, id := range ids {
+		out, err := t.call(ctx, "getEpochReceivedStake", bigEpoch, id)
+		if err != nil {
+			return nil, fmt.Errorf("getEpochReceivedStake: %w", err)
+		}
+		stake, ok := out[0].(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("getEpochReceivedStake: unexpected output %T", out[0])
+		}
+
+		out, err = t.call(ctx, "getValidator", id)
+		if err != nil {
+			return nil, fmt.Errorf("getValidator: %w", err)
+		}
+		address, ok := out[6].(common.Address)
+		if !ok {
+			return nil, fmt.Errorf("getValidator: unexpected auth output %T", out[6])
+		}
+
+		v := &validator{
+			ID:      idx.ValidatorID(id.Uint64()),
+			Stake:   stake,
+			Address: address,
+		}
+		vs.Validators[v.ID] = v
+		vs.Total.Add(vs.Total, stake)
+	}
+
+	t.epochs[epoch] = vs
+	return vs, nil
+}
+
+// formatStake formats the stake in FTM
+func formatStake(stake *big.Int) string {
+	ftm := new(big.Int).Div(stake, big.NewInt(1e18))
+	return ftm.String() + " FTM"
+}
+
+// annotateClusters adds the stakes and addresses of the validators to the labels of their clusters
+func annotateClusters(vs *validatorSet, subGraphs map[string]*dot.SubGraph, creators map[string]idx.ValidatorID) {
+	for group, sg := range subGraphs {
+		v, ok := vs.Validators[creators[group]]
+		if !ok {
+			checkAttrs("cluster "+group, sg.SetLabel(group+"\nnot a validator"))
+			continue
+		}
+		checkAttrs("cluster "+group,
+			sg.SetLabel(fmt.Sprintf("%s\n%s (%.2f%%)\n%s", group, formatStake(v.Stake), vs.Weight(v.Stake), v.Address.Hex())))
+	}
+}
+
+// orderByStake sorts the cluster names by stake of the validators, the largest first
+func orderByStake(names []string, vs *validatorSet, creators map[string]idx.ValidatorID) {
+	stake := func(group string) *big.Int {
+		if v, ok := vs.Validators[creators[group]]; ok {
+			return v.Stake
+		}
+		return new(big.Int)
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return stake(names[i]).Cmp(stake(names[j])) > 0
+	})
+}
+
+// stalledFrames is how many frames the last event of a validator is behind the highest frame to report it as stalled
+const stalledFrames = 2
+
+// lastFrames returns the frame of the last event of every creator
+func lastFrames(nodes map[hash.Event]*types.EventNode) map[idx.ValidatorID]idx.Frame {
+	frames := make(map[idx.ValidatorID]idx.Frame)
+	for _, n := range nodes {
+		if f, ok := frames[n.Creator()]; !ok || n.Frame() > f {
+			frames[n.Creator()] = n.Frame()
+		}
+	}
+	return frames
+}
+
+// missingLabel describes the validators with no events and the validators whose last event is
+// stalledFrames or more behind the highest frame, with their weight. It is "" if there are none.
+func missingLabel(vs *validatorSet, frames map[idx.ValidatorID]idx.Frame) string {
+	var top idx.Frame
+	for _, f := range frames {
+		if f > top {
+			top = f
+		}
+	}
+
+	ids := make([]int, 0, len(vs.Validators))
+	for id := range vs.Validators {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	absent, stalled := make([]string, 0), make([]string, 0)
+	absentStake, stalledStake := new(big.Int), new(big.Int)
+	for _, id := range ids {
+		v := vs.Validators[idx.ValidatorID(id)]
+		f, ok := frames[v.ID]
+		switch {
+		case !ok:
+			absent = append(absent, strconv.Itoa(id))
+			absentStake.Add(absentStake, v.Stake)
+		case top-f >= stalledFrames:
+			stalled = append(stalled, fmt.Sprintf("%d (frame %d)", id, f))
+			stalledStake.Add(stalledStake, v.Stake)
+		}
+	}
+
+	lines := make([]string, 0, 2)
+	if len(absent) > 0 {
+		lines = append(lines, fmt.Sprintf("epoch %d: no events of validators %s, missing weight %s (%.2f%%)",
+			vs.Epoch, strings.Join(absent, ", "), formatStake(absentStake), vs.Weight(absentStake)))
+	}
+	if len(stalled) > 0 {
+		lines = append(lines, fmt.Sprintf("epoch %d: validators %s stalled, frame %d reached, stalled weight %s (%.2f%%)",
+			vs.Epoch, strings.Join(stalled, ", "), top, formatStake(stalledStake), vs.Weight(stalledStake)))
+	}
+	return strings.Join(lines, "\n")
+}
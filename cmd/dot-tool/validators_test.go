@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+)
+
+func ftm(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18))
+}
+
+func testValidators(stakes ...int64) *validatorSet {
+	vs := &validatorSet{
+		Epoch:      5,
+		Validators: make(map[idx.ValidatorID]*validator),
+		Total:      new(big.Int),
+	}
+	for i, stake := range stakes {
+		v := &validator{ID: idx.ValidatorID(i + 1), Stake: ftm(stake)}
+		vs.Validators[v.ID] = v
+		vs.Total.Add(vs.Total, v.Stake)
+	}
+	return vs
+}
+
+func TestWeight(t *testing.T) {
+	vs := testValidators(1, 3)
+	if w := vs.Weight(ftm(1)); w != 25 {
+		t.Errorf("weight %f", w)
+	}
+	if w := (&validatorSet{Total: new(big.Int)}).Weight(ftm(1)); w != 0 {
+		t.Errorf("weight of the empty set %f", w)
+	}
+}
+
+func TestOrderByStake(t *testing.T) {
+	vs := testValidators(1, 3, 2)
+	creators := map[string]idx.ValidatorID{"host-1": 1, "host-2": 2, "host-3": 3, "host-4": 4, "host-5": 5}
+	names := []string{"host-1", "host-2", "host-3", "host-4", "host-5"}
+	orderByStake(names, vs, creators)
+
+	// creators which are not validators go last in their order
+	for i, want := range []string{"host-2", "host-3", "host-1", "host-4", "host-5"} {
+		if names[i] != want {
+			t.Fatalf("order %v", names)
+		}
+	}
+}
+
+func TestMissingLabel(t *testing.T) {
+	vs := testValidators(1, 1, 1, 1)
+
+	if label := missingLabel(vs, map[idx.ValidatorID]idx.Frame{1: 10, 2: 9, 3: 10, 4: 10}); label != "" {
+		t.Errorf("all validators are producing: %q", label)
+	}
+
+	// validator 3 stopped mid-epoch, validator 4 has no events
+	label := missingLabel(vs, map[idx.ValidatorID]idx.Frame{1: 10, 2: 9, 3: 8})
+	want := "epoch 5: no events of validators 4, missing weight 1 FTM (25.00%)\n" +
+		"epoch 5: validators 3 (frame 8) stalled, frame 10 reached, stalled weight 1 FTM (25.00%)"
+	if label != want {
+		t.Errorf("%q != %q", label, want)
+	}
+}
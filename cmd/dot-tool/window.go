@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// Positions of an event relative to the window
+const (
+	belowWindow = -1
+	inWindow    = 0
+	aboveWindow = 1
+)
+
+// window selects the events to draw by lamport, frame and creation time ranges.
+// Zero bounds are open. Events next to the window are drawn as stubs.
+type window struct {
+	MinLamport, MaxLamport uint64
+	MinFrame, MaxFrame     uint64
+	Since, Until           time.Time
+}
+
+// parseRange parses "from..to" of unsigned integers, either bound may be omitted
+func parseRange(s string) (from, to uint64, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	parts := strings.Split(s, "..")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("range %q is not from..to", s)
+	}
+	if parts[0] != "" {
+		if from, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	if parts[1] != "" {
+		if to, err = strconv.ParseUint(parts[1], 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	if to != 0 && from > to {
+		return 0, 0, fmt.Errorf("range %q is empty", s)
+	}
+	return from, to, nil
+}
+
+// parseTimeRange parses "from..to" of RFC3339 times, either bound may be omitted
+func parseTimeRange(s string) (since, until time.Time, err error) {
+	if s == "" {
+		return
+	}
+	parts := strings.Split(s, "..")
+	if len(parts) != 2 {
+		return since, until, fmt.Errorf("time range %q is not from..to", s)
+	}
+	if parts[0] != "" {
+		if since, err = time.Parse(time.RFC3339, parts[0]); err != nil {
+			return
+		}
+	}
+	if parts[1] != "" {
+		if until, err = time.Parse(time.RFC3339, parts[1]); err != nil {
+			return
+		}
+	}
+	if !until.IsZero() && since.After(until) {
+		err = fmt.Errorf("time range %q is empty", s)
+	}
+	return
+}
+
+// Position returns the position of the event relative to the window
+func (w *window) Position(e *types.EventNode) int {
+	lamport := uint64(e.Lamport())
+	frame := uint64(e.Frame())
+	created := e.CreationTime().Time()
+
+	if lamport < w.MinLamport || frame < w.MinFrame || !w.Since.IsZero() && created.Before(w.Since) {
+		return belowWindow
+	}
+	if w.MaxLamport != 0 && lamport > w.MaxLamport ||
+		w.MaxFrame != 0 && frame > w.MaxFrame ||
+		!w.Until.IsZero() && created.After(w.Until) {
+		return aboveWindow
+	}
+	return inWindow
+}
+
+// Passed returns true if the event and all its ancestors are below the window.
+// Lamport times and frames do not grow towards the parents, but creation times
+// of other creators may, so the time bounds never cut the walk.
+func (w *window) Passed(e *types.EventNode) bool {
+	return uint64(e.Lamport()) < w.MinLamport || uint64(e.Frame()) < w.MinFrame
+}
+
+// setStub makes the node a collapsed boundary stub of an event outside the window
+func setStub(n *dot.Node, e *types.EventNode) {
+	checkAttrs("stub "+e.ID().String(), n.SetLabel(e.ID().String()))
+	n.SetShape(dot.ShapeBox)
+	n.SetStyle(dot.StyleDashed)
+	n.SetFontSize(8)
+	checkAttrs("stub "+e.ID().String(), n.Set("height", "0.2"))
+}
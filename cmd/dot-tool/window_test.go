@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Fantom-foundation/go-opera/inter"
+	"github.com/Fantom-foundation/lachesis-base/inter/dag"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+
+	"github.com/Fantom-foundation/dag2dot-tool/types"
+)
+
+// testEvent is an event of the tests, only the base fields and the creation time are implemented
+type testEvent struct {
+	*dag.BaseEvent
+	operaEvent
+	created time.Time
+}
+
+type operaEvent struct {
+	inter.EventI
+}
+
+func (operaEvent) MedianTime() inter.Timestamp { return 0 }
+func (operaEvent) GasPowerUsed() uint64        { return 0 }
+
+func (e *testEvent) CreationTime() inter.Timestamp {
+	return inter.Timestamp(e.created.UnixNano())
+}
+
+func newTestEvent(lamport idx.Lamport, frame idx.Frame, created time.Time) *types.EventNode {
+	e := &dag.MutableBaseEvent{}
+	e.SetEpoch(1)
+	e.SetLamport(lamport)
+	e.SetFrame(frame)
+	return types.NewEventNode(&testEvent{BaseEvent: e.Build([24]byte{}), created: created})
+}
+
+func TestParseRange(t *testing.T) {
+	for _, c := range []struct {
+		s        string
+		from, to uint64
+		ok       bool
+	}{
+		{"", 0, 0, true},
+		{"10..12", 10, 12, true},
+		{"10..", 10, 0, true},
+		{"..12", 0, 12, true},
+		{"12..10", 0, 0, false},
+		{"10", 0, 0, false},
+		{"a..b", 0, 0, false},
+	} {
+		from, to, err := parseRange(c.s)
+		if (err == nil) != c.ok || from != c.from || to != c.to {
+			t.Errorf("%q: %d..%d, %v", c.s, from, to, err)
+		}
+	}
+}
+
+func TestWindowPosition(t *testing.T) {
+	t0 := time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)
+	w := &window{MinLamport: 10, MaxLamport: 20, MinFrame: 2, Since: t0, Until: t0.Add(time.Minute)}
+
+	for _, c := range []struct {
+		lamport idx.Lamport
+		frame   idx.Frame
+		created time.Time
+		pos     int
+		passed  bool
+	}{
+		{15, 3, t0.Add(time.Second), inWindow, false},
+		{9, 3, t0.Add(time.Second), belowWindow, true},
+		{15, 1, t0.Add(time.Second), belowWindow, true},
+		{21, 3, t0.Add(time.Second), aboveWindow, false},
+		{15, 3, t0.Add(2 * time.Minute), aboveWindow, false},
+		// parents of an event created before the window may be created inside it
+		{15, 3, t0.Add(-time.Second), belowWindow, false},
+	} {
+		e := newTestEvent(c.lamport, c.frame, c.created)
+		if pos := w.Position(e); pos != c.pos {
+			t.Errorf("%d/%d/%s: position %d != %d", c.lamport, c.frame, c.created, pos, c.pos)
+		}
+		if passed := w.Passed(e); passed != c.passed {
+			t.Errorf("%d/%d/%s: passed %t != %t", c.lamport, c.frame, c.created, passed, c.passed)
+		}
+	}
+
+	// the empty window selects all the events
+	if pos := (&window{}).Position(newTestEvent(1, 1, t0)); pos != inWindow {
+		t.Errorf("empty window: position %d", pos)
+	}
+}
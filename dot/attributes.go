@@ -0,0 +1,223 @@
+package dot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kinds of the graph objects for the attribute validation
+const (
+	KindGraph    = "graph"
+	KindSubgraph = "subgraph"
+	KindCluster  = "cluster"
+	KindNode     = "node"
+	KindEdge     = "edge"
+)
+
+// subgraphAttributes are the attributes of the subgraphs which are not clusters
+var subgraphAttributes = []string{"rank"}
+
+// InvalidAttributeError is returned when the attribute is not used by the kind
+// of the object or its value has a wrong type. It wraps AttributeError.
+type InvalidAttributeError struct {
+	Kind  string
+	Name  string
+	Value string
+	// Reason is empty if the attribute is unknown
+	Reason string
+}
+
+func (e *InvalidAttributeError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s: %s attribute %q", AttributeError, e.Kind, e.Name)
+	}
+	return fmt.Sprintf("%s: %s attribute %q: %q %s", AttributeError, e.Kind, e.Name, e.Value, e.Reason)
+}
+
+func (e *InvalidAttributeError) Unwrap() error {
+	return AttributeError
+}
+
+// attributeKind returns the kind of the graph for the attribute validation
+func (g *Graph) attributeKind() string {
+	if g.graphType != SUBGRAPH {
+		return KindGraph
+	}
+	if strings.HasPrefix(g.name, "cluster") {
+		return KindCluster
+	}
+	return KindSubgraph
+}
+
+// attributesOf returns the attributes used by the kind of objects
+func attributesOf(kind string) []string {
+	switch kind {
+	case KindGraph:
+		return graphAttributes
+	case KindSubgraph:
+		return subgraphAttributes
+	case KindCluster:
+		return clusterAttributes
+	case KindNode:
+		return nodeAttributes
+	case KindEdge:
+		return edgeAttributes
+	}
+	return nil
+}
+
+// valueType checks a value, it returns the reason if the value is invalid
+type valueType func(value string) string
+
+var (
+	colorRe = regexp.MustCompile(`^(#[0-9a-fA-F]{6}([0-9a-fA-F]{2})?|/[a-zA-Z0-9]*/[a-zA-Z0-9]+|[a-zA-Z][a-zA-Z0-9]*|[0-9.]+[, ]+[0-9.]+[, ]+[0-9.]+)$`)
+	styleRe = regexp.MustCompile(`^[a-z]+(\(.*\))?$`)
+)
+
+func colorType(value string) string {
+	if colorRe.MatchString(strings.TrimSpace(value)) {
+		return ""
+	}
+	return "is not a color"
+}
+
+// colorListType is a color or a list of colors with optional weights "red;0.3:blue"
+func colorListType(value string) string {
+	for _, item := range strings.Split(value, ":") {
+		parts := strings.SplitN(item, ";", 2)
+		if reason := colorType(parts[0]); reason != "" {
+			return "is not a color list"
+		}
+		if len(parts) == 2 && doubleType(parts[1]) != "" {
+			return "is not a color list"
+		}
+	}
+	return ""
+}
+
+func doubleType(value string) string {
+	if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+		return "is not a double"
+	}
+	return ""
+}
+
+func intType(value string) string {
+	if _, err := strconv.Atoi(strings.TrimSpace(value)); err != nil {
+		return "is not an integer"
+	}
+	return ""
+}
+
+func boolType(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "false", "yes", "no":
+		return ""
+	}
+	if intType(value) == "" {
+		return ""
+	}
+	return "is not a bool"
+}
+
+func enumType(values ...string) valueType {
+	return func(value string) string {
+		if indexInSlice(values, value) >= 0 {
+			return ""
+		}
+		return "is not one of " + strings.Join(values, ", ")
+	}
+}
+
+// styleType is a comma separated list of style names, setlinewidth(2) like styles are allowed
+func styleType(value string) string {
+	for _, item := range strings.Split(value, ",") {
+		if !styleRe.MatchString(strings.TrimSpace(item)) {
+			return "is not a style"
+		}
+	}
+	return ""
+}
+
+// attributeTypes are the value types of the typed attributes, other attributes are strings
+var attributeTypes = map[string]valueType{
+	"color":          colorListType,
+	"fillcolor":      colorListType,
+	"bgcolor":        colorListType,
+	"pencolor":       colorType,
+	"fontcolor":      colorType,
+	"labelfontcolor": colorType,
+
+	"penwidth":      doubleType,
+	"arrowsize":     doubleType,
+	"fontsize":      doubleType,
+	"labelfontsize": doubleType,
+	"height":        doubleType,
+	"width":         doubleType,
+	"nodesep":       doubleType,
+	"labelangle":    doubleType,
+	"labeldistance": doubleType,
+	"weight":        doubleType,
+
+	"peripheries": intType,
+	"sortv":       intType,
+	"minlen":      intType,
+	"sides":       intType,
+
+	"constraint":  boolType,
+	"compound":    boolType,
+	"newrank":     boolType,
+	"center":      boolType,
+	"concentrate": boolType,
+	"headclip":    boolType,
+	"tailclip":    boolType,
+	"labelfloat":  boolType,
+	"regular":     boolType,
+	"nojustify":   boolType,
+	"landscape":   boolType,
+
+	"rank":        enumType("same", "min", "source", "max", "sink"),
+	"clusterrank": enumType("local", "global", "none"),
+	"rankdir":     enumType("TB", "LR", "BT", "RL"),
+	"dir":         enumType("forward", "back", "both", "none"),
+	"labeljust":   enumType("l", "r", "c"),
+	"outputorder": enumType("breadthfirst", "nodesfirst", "edgesfirst"),
+	"ordering":    enumType("in", "out", ""),
+
+	"style": styleType,
+}
+
+// validateAttribute checks the attribute name for the kind of the object and the value type
+func validateAttribute(kind, name, value string) error {
+	// clusters are subgraphs too
+	valid := validAttribute(attributesOf(kind), name) ||
+		kind == KindCluster && validAttribute(subgraphAttributes, name)
+	if !valid {
+		return &InvalidAttributeError{Kind: kind, Name: name, Value: value}
+	}
+	// labelloc is an enum of the graphs and nodes, but different ones
+	if name == "labelloc" {
+		values := []string{"t", "b"}
+		if kind == KindNode {
+			values = append(values, "c")
+		}
+		if reason := enumType(values...)(value); reason != "" {
+			return &InvalidAttributeError{Kind: kind, Name: name, Value: value, Reason: reason}
+		}
+		return nil
+	}
+	if isHTML(value) && validAttribute(htmlAttributes, name) {
+		if reason := htmlType(value); reason != "" {
+			return &InvalidAttributeError{Kind: kind, Name: name, Value: value, Reason: reason}
+		}
+		return nil
+	}
+	if check, ok := attributeTypes[name]; ok {
+		if reason := check(value); reason != "" {
+			return &InvalidAttributeError{Kind: kind, Name: name, Value: value, Reason: reason}
+		}
+	}
+	return nil
+}
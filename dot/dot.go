@@ -15,6 +15,7 @@ package dot
 import (
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
 	"sort"
 	"strings"
@@ -48,7 +49,7 @@ var edgeAttributes = []string{"URL", "arrowhead", "arrowsize", "arrowtail",
 	"labeltooltip", "layer", "len", "lhead", "lp", "ltail", "minlen",
 	"nojustify", "penwidth", "pos", "samehead", "sametail", "showboxes",
 	"style", "tailURL", "tailclip", "tailhref", "taillabel", "tailport",
-	"tailtarget", "tailtooltip", "target", "tooltip", "weight",
+	"tailtarget", "tailtooltip", "target", "tooltip", "weight", "xlabel",
 	// for subgraphs
 	"rank"}
 
@@ -58,7 +59,7 @@ var nodeAttributes = []string{"URL", "color", "colorscheme", "comment",
 	"labelloc", "layer", "margin", "nojustify", "orientation", "penwidth",
 	"peripheries", "pin", "pos", "rects", "regular", "root", "samplepoints",
 	"shape", "shapefile", "showboxes", "sides", "skew", "sortv", "style",
-	"target", "tooltip", "vertices", "width", "z",
+	"target", "tooltip", "vertices", "width", "xlabel", "z",
 	// The following are attributes dot2tex
 	"texlbl", "texmode"}
 
@@ -166,17 +167,18 @@ func indexInSlice(slice []string, toFind string) int {
 }
 
 var alreadyQuotedRegex = regexp.MustCompile("^\".+\"$")
-var validIdentifierRegexWithPort = regexp.MustCompile("^[_a-zA-Z][a-zA-Z0-9_,:\"]*[a-zA-Z0-9_,\"]+$")
-var validIdentifierRegex = regexp.MustCompile("^[_a-zA-Z][a-zA-Z0-9_,]*$")
+var validIdentifierRegexWithPort = regexp.MustCompile("^[_a-zA-Z][a-zA-Z0-9_:\"]*[a-zA-Z0-9_\"]+$")
+var validIdentifierRegex = regexp.MustCompile("^[_a-zA-Z][a-zA-Z0-9_]*$")
+var numeralRegex = regexp.MustCompile(`^-?(\.[0-9]+|[0-9]+(\.[0-9]*)?)$`)
 
 func needsQuotes(s string) bool {
 	if indexInSlice(dotKeywords, s) != -1 {
 		return false
 	}
-	if alreadyQuotedRegex.MatchString(s) {
+	if alreadyQuotedRegex.MatchString(s) || isHTML(s) {
 		return false
 	}
-	if validIdentifierRegexWithPort.MatchString(s) || validIdentifierRegex.MatchString(s) {
+	if validIdentifierRegexWithPort.MatchString(s) || validIdentifierRegex.MatchString(s) || numeralRegex.MatchString(s) {
 		return false
 	}
 
@@ -185,14 +187,27 @@ func needsQuotes(s string) bool {
 
 func QuoteIfNecessary(s string) (result string) {
 	if needsQuotes(s) {
-		s = strings.Replace(s, "\"", "\\\"", -1)
-		s = strings.Replace(s, "\n", "\\n", -1)
-		s = strings.Replace(s, "\r", "\\r", -1)
-		s = "\"" + s + "\""
+		s = quote(s)
 	}
 	return s
 }
 
+// quoteValue quotes an attribute value unless it is a plain identifier or a numeral,
+// the "node:port" form is valid for the node IDs only
+func quoteValue(s string) string {
+	if validIdentifierRegex.MatchString(s) || numeralRegex.MatchString(s) {
+		return s
+	}
+	return quote(s)
+}
+
+func quote(s string) string {
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	s = strings.Replace(s, "\n", "\\n", -1)
+	s = strings.Replace(s, "\r", "\\r", -1)
+	return "\"" + s + "\""
+}
+
 func validAttribute(attributeCollection []string, attributeName string) bool {
 	return indexInSlice(attributeCollection, attributeName) != -1
 }
@@ -252,32 +267,50 @@ func (c *common) Set(attributeName, attributeValue string) error {
 	return nil
 }
 
-func setAttribute(validAttributes []string, attributes map[string]string, attributeName, attributeValue string) error {
-	if validAttribute(validAttributes, attributeName) {
-		attributes[attributeName] = attributeValue
-		return nil
+// Attributes returns a copy of the attributes
+func (c *common) Attributes() map[string]string {
+	res := make(map[string]string, len(c.attributes))
+	for k, v := range c.attributes {
+		res[k] = v
+	}
+	return res
+}
+
+// ResetAttributes replaces the attributes with a copy of the given ones, they are not validated again
+func (c *common) ResetAttributes(attributes map[string]string) {
+	c.attributes = make(map[string]string, len(attributes))
+	for k, v := range attributes {
+		c.attributes[k] = v
 	}
-	return AttributeError
 }
 
+func setAttribute(kind string, attributes map[string]string, attributeName, attributeValue string) error {
+	if err := validateAttribute(kind, attributeName, attributeValue); err != nil {
+		return err
+	}
+	attributes[attributeName] = attributeValue
+	return nil
+}
+
+// Set the attribute of the graph, subgraph or cluster (a subgraph named "cluster...")
 func (g *Graph) Set(attributeName, attributeValue string) error {
-	return setAttribute(graphAttributes, g.common.attributes, attributeName, attributeValue)
+	return setAttribute(g.attributeKind(), g.common.attributes, attributeName, attributeValue)
 }
 
 func (g *Graph) SetGlobalNodeAttr(attributeName, attributeValue string) error {
-	return setAttribute(nodeAttributes, g.nodeAttributes, attributeName, attributeValue)
+	return setAttribute(KindNode, g.nodeAttributes, attributeName, attributeValue)
 }
 
 func (g *Graph) SetGlobalEdgeAttr(attributeName, attributeValue string) error {
-	return setAttribute(edgeAttributes, g.edgeAttributes, attributeName, attributeValue)
+	return setAttribute(KindEdge, g.edgeAttributes, attributeName, attributeValue)
 }
 
 func (n *Node) Set(attributeName, attributeValue string) error {
-	return setAttribute(nodeAttributes, n.common.attributes, attributeName, attributeValue)
+	return setAttribute(KindNode, n.common.attributes, attributeName, attributeValue)
 }
 
 func (e *Edge) Set(attributeName, attributeValue string) error {
-	return setAttribute(edgeAttributes, e.common.attributes, attributeName, attributeValue)
+	return setAttribute(KindEdge, e.common.attributes, attributeName, attributeValue)
 }
 
 func (c *common) setSequence(sequence int) {
@@ -351,52 +384,126 @@ func (g *Graph) GetSubgraphs() (result []*SubGraph) {
 	return result
 }
 
-func (g Graph) String() string {
-	var parts []string
-	if g.strict {
-		parts = append(parts, "strict ")
+// Nodes returns the nodes added to the graph in insertion order
+func (g *Graph) Nodes() []*Node {
+	result := make([]*Node, 0, len(g.nodes))
+	for _, nodes := range g.nodes {
+		result = append(result, nodes...)
 	}
-	if g.name == "" {
-		parts = append(parts, "{\n")
-	} else {
-		parts = append(parts, fmt.Sprintf("%s %s {\n", g.graphType, QuoteIfNecessary(g.name)))
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Sequence() < result[j].Sequence()
+	})
+	return result
+}
+
+// Edges returns the edges added to the graph in insertion order
+func (g *Graph) Edges() []*Edge {
+	result := make([]*Edge, 0, len(g.edges))
+	for _, edges := range g.edges {
+		result = append(result, edges...)
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Sequence() < result[j].Sequence()
+	})
+	return result
+}
 
-	if len(g.attributes) > 0 {
-		attrs := make([]string, 0, len(g.attributes))
-		for _, key := range sortedKeys(g.attributes) {
-			attrs = append(attrs, "  "+key+"="+QuoteIfNecessary(g.attributes[key]))
+// SortObjects renumbers the nodes, edges and subgraphs of the graph and of its subgraphs in the order of less,
+// so the output does not depend on the insertion order. Objects equal by less keep their relative order.
+func (g *Graph) SortObjects(less func(a, b GraphObject) bool) {
+	objectList := make(graphObjects, 0)
+	for _, nodes := range g.nodes {
+		for _, node := range nodes {
+			objectList = append(objectList, node)
 		}
-		if len(attrs) > 0 {
-			parts = append(parts, "graph [\n")
-			parts = append(parts, strings.Join(attrs, ";\n"))
-			parts = append(parts, ";\n];\n")
+	}
+	for _, edges := range g.edges {
+		for _, edge := range edges {
+			objectList = append(objectList, edge)
 		}
 	}
-
-	if len(g.nodeAttributes) > 0 {
-		attrs := make([]string, 0, len(g.nodeAttributes))
-		for _, key := range sortedKeys(g.nodeAttributes) {
-			attrs = append(attrs, "  "+key+"="+QuoteIfNecessary(g.nodeAttributes[key]))
+	for _, subgraphs := range g.subgraphs {
+		for _, subgraph := range subgraphs {
+			objectList = append(objectList, subgraph)
+			subgraph.SortObjects(less)
 		}
-		if len(attrs) > 0 {
-			parts = append(parts, "node [\n")
-			parts = append(parts, strings.Join(attrs, ";\n"))
-			parts = append(parts, ";\n];\n")
+	}
+	sort.Sort(objectList)
+	sort.SliceStable(objectList, func(i, j int) bool {
+		return less(objectList[i], objectList[j])
+	})
+
+	for i, obj := range objectList {
+		switch o := obj.(type) {
+		case *Node:
+			o.setSequence(i + 1)
+		case *Edge:
+			o.setSequence(i + 1)
+		case *SubGraph:
+			o.setSequence(i + 1)
 		}
 	}
+	g.currentChildSequence = len(objectList) + 1
+}
 
-	if len(g.edgeAttributes) > 0 {
-		attrs := make([]string, 0, len(g.edgeAttributes))
-		for _, key := range sortedKeys(g.edgeAttributes) {
-			attrs = append(attrs, "  "+key+"="+QuoteIfNecessary(g.edgeAttributes[key]))
-		}
-		if len(attrs) > 0 {
-			parts = append(parts, "edge [\n")
-			parts = append(parts, strings.Join(attrs, ";\n"))
-			parts = append(parts, ";\n];\n")
-		}
+func (g Graph) String() string {
+	var b strings.Builder
+	_, _ = g.WriteTo(&b)
+	return b.String()
+}
+
+// countingWriter keeps the count of written bytes and the first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) write(s string) {
+	if cw.err != nil {
+		return
 	}
+	n, err := io.WriteString(cw.w, s)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+// WriteTo streams the graph in sequence order without building the whole document in memory
+func (g *Graph) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	g.writeTo(cw)
+	return cw.n, cw.err
+}
+
+func writeAttributes(cw *countingWriter, kind string, attributes map[string]string) {
+	if len(attributes) == 0 {
+		return
+	}
+	attrs := make([]string, 0, len(attributes))
+	for _, key := range sortedKeys(attributes) {
+		attrs = append(attrs, "  "+formatAttribute(key, attributes[key]))
+	}
+	cw.write(kind + " [\n")
+	cw.write(strings.Join(attrs, ";\n"))
+	cw.write(";\n];\n")
+}
+
+func (g *Graph) writeTo(cw *countingWriter) {
+	if g.strict {
+		cw.write("strict ")
+	}
+	switch {
+	case g.name == "" && g.graphType == SUBGRAPH:
+		cw.write("{\n")
+	case g.name == "":
+		cw.write(fmt.Sprintf("%s {\n", g.graphType))
+	default:
+		cw.write(fmt.Sprintf("%s %s {\n", g.graphType, QuoteIfNecessary(g.name)))
+	}
+
+	writeAttributes(cw, "graph", g.attributes)
+	writeAttributes(cw, "node", g.nodeAttributes)
+	writeAttributes(cw, "edge", g.edgeAttributes)
 
 	objectList := make(graphObjects, 0)
 
@@ -419,18 +526,23 @@ func (g Graph) String() string {
 
 	for _, obj := range objectList {
 		//@todo type-based decision making re: supressDisconnected and simplify
-		//switch o := obj.(type) {
-		//case *Node:
-		//}
-		parts = append(parts, fmt.Sprintf("%s\n", obj))
+		switch o := obj.(type) {
+		case *SubGraph:
+			o.Graph.writeTo(cw)
+		case fmt.Stringer:
+			cw.write(o.String())
+		}
+		cw.write("\n")
+		if cw.err != nil {
+			return
+		}
 	}
 
 	for _, nodes := range g.sameRank {
-		parts = append(parts, fmt.Sprintf("{ rank=same %s }", strings.Join(nodes, " ")))
+		cw.write(fmt.Sprintf("{ rank=same %s }", strings.Join(nodes, " ")))
 	}
 
-	parts = append(parts, "}\n")
-	return strings.Join(parts, "")
+	cw.write("}\n")
 }
 
 type Node struct {
@@ -454,12 +566,7 @@ func (n Node) String() string {
 
 	attrs := make([]string, 0)
 	for _, key := range sortedKeys(n.attributes) {
-		value := n.attributes[key]
-		if key == "label" && len(value) > 4 && value[0] == '<' && value[len(value)-1] == '>' {
-			attrs = append(attrs, key+"="+value)
-		} else {
-			attrs = append(attrs, key+"="+QuoteIfNecessary(value))
-		}
+		attrs = append(attrs, formatAttribute(key, n.attributes[key]))
 	}
 	if len(attrs) > 0 {
 		parts = append(parts, strings.Join(attrs, ", "))
@@ -512,7 +619,7 @@ func (e Edge) String() string {
 
 	attrs := make([]string, 0)
 	for _, key := range sortedKeys(e.attributes) {
-		attrs = append(attrs, key+"="+QuoteIfNecessary(e.attributes[key]))
+		attrs = append(attrs, formatAttribute(key, e.attributes[key]))
 	}
 	if len(attrs) > 0 {
 		parts = append(parts, " [")
@@ -528,4 +635,6 @@ func init() {
 	sort.Strings(nodeAttributes)
 	sort.Strings(edgeAttributes)
 	sort.Strings(clusterAttributes)
+	sort.Strings(subgraphAttributes)
+	sort.Strings(htmlAttributes)
 }
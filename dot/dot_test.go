@@ -1,7 +1,11 @@
 package dot_test
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/Fantom-foundation/dag2dot-tool/dot"
@@ -13,6 +17,10 @@ func TestQuotingIfNecessary(t *testing.T) {
 		"\"foo\"":   "\"foo\"",
 		"foo bar":   "\"foo bar\"",
 		"Allen, C.": "\"Allen, C.\"",
+		"a,b":       "\"a,b\"",
+		"2.5":       "2.5",
+		"-.5":       "-.5",
+		"1.2.3":     "\"1.2.3\"",
 	}
 
 	for input, expected := range cases {
@@ -84,11 +92,177 @@ func TestGraphAttributeSetting(t *testing.T) {
 		t.Error("Error setting value on g", g)
 	}
 	g.Set("Damping", "x")
-	if g.Set("this_does_not_exist", "and_should_error") != dot.AttributeError {
+	if !errors.Is(g.Set("this_does_not_exist", "and_should_error"), dot.AttributeError) {
 		t.Error("Did not get godot.AttributeError when setting invalid attribute on g", g)
 	}
 }
 
+func TestResetAttributes(t *testing.T) {
+	n := dot.NewNode("a")
+	n.Set("label", "foo")
+	attrs := n.Attributes()
+	attrs["color"] = "red"
+	if n.Get("color") != "" {
+		t.Error("Attributes is not a copy")
+	}
+
+	n.ResetAttributes(attrs)
+	attrs["label"] = "bar"
+	if n.Get("color") != "red" || n.Get("label") != "foo" {
+		t.Errorf("Reset attributes: %s", n)
+	}
+}
+
+func TestAttributeKinds(t *testing.T) {
+	g := dot.NewGraph("G")
+	sg := dot.NewSubgraph("SG")
+	cluster := dot.NewSubgraph("cluster0")
+	n := dot.NewNode("n")
+	e := dot.NewEdge(n, dot.NewNode("m"))
+
+	cases := []struct {
+		obj   dot.GraphObject
+		name  string
+		valid bool
+	}{
+		{g, "rankdir", true},
+		{g, "pencolor", false},
+		{sg, "rank", true},
+		{sg, "pencolor", false},
+		{cluster, "pencolor", true},
+		{cluster, "fillcolor", true},
+		{cluster, "peripheries", true},
+		{cluster, "rankdir", false},
+		{n, "peripheries", true},
+		{n, "constraint", false},
+		{e, "constraint", true},
+		{e, "shape", false},
+	}
+
+	for _, c := range cases {
+		value := map[string]string{"rankdir": "LR", "rank": "same", "peripheries": "2", "constraint": "false", "shape": "box"}[c.name]
+		if value == "" {
+			value = "red"
+		}
+		err := c.obj.Set(c.name, value)
+		if c.valid && err != nil {
+			t.Errorf("%s %s: %v", c.obj.Type(), c.name, err)
+		}
+		if !c.valid && !errors.Is(err, dot.AttributeError) {
+			t.Errorf("%s %s: '%v' is not AttributeError", c.obj.Type(), c.name, err)
+		}
+	}
+}
+
+func TestAttributeValues(t *testing.T) {
+	cases := []struct {
+		name, value string
+		valid       bool
+	}{
+		{"color", "red", true},
+		{"color", "#FFAA00", true},
+		{"color", "#ffaa0080", true},
+		{"color", "/x11/red", true},
+		{"color", "0.5 0.2 1.0", true},
+		{"color", "red:blue;0.3", true},
+		{"color", "#FFAA0", false},
+		{"color", "not a color!", false},
+		{"penwidth", "2.5", true},
+		{"penwidth", "wide", false},
+		{"peripheries", "2", true},
+		{"peripheries", "2.5", false},
+		{"regular", "true", true},
+		{"regular", "Yes", true},
+		{"regular", "maybe", false},
+		{"style", "filled,dashed", true},
+		{"style", "setlinewidth(2)", true},
+		{"style", "filled;dashed", false},
+		{"labelloc", "c", true},
+		{"labelloc", "middle", false},
+		{"label", "anything goes", true},
+	}
+
+	for _, c := range cases {
+		n := dot.NewNode("n")
+		err := n.Set(c.name, c.value)
+		if c.valid && err != nil {
+			t.Errorf("%s=%q: %v", c.name, c.value, err)
+		}
+		if !c.valid {
+			var attrErr *dot.InvalidAttributeError
+			if !errors.As(err, &attrErr) || attrErr.Name != c.name || attrErr.Reason == "" {
+				t.Errorf("%s=%q: bad error '%v'", c.name, c.value, err)
+			}
+		}
+	}
+
+	g := dot.NewGraph("G")
+	if err := g.Set("labelloc", "c"); !errors.Is(err, dot.AttributeError) {
+		t.Errorf("graph labelloc=c: '%v' is not AttributeError", err)
+	}
+}
+
+func TestTypedAttributes(t *testing.T) {
+	n := dot.NewNode("n")
+	n.SetShape(dot.ShapeBox)
+	n.SetStyle(dot.StyleFilled | dot.StyleDashed)
+	n.SetFillColor(dot.RGB(255, 170, 0))
+	n.SetPenWidth(2.5)
+	n.SetFontSize(8)
+
+	expected := `n [fillcolor="#FFAA00", fontsize=8, penwidth=2.5, shape=box, style="dashed,filled"];`
+	if fmt.Sprint(n) != expected {
+		t.Errorf("'%s' != '%s'", n, expected)
+	}
+
+	e := dot.NewEdge(n, dot.NewNode("m"))
+	e.SetConstraint(false)
+	e.SetColor(dot.MustParseColor("red"))
+	if e.Get("constraint") != "false" || e.Get("color") != "red" {
+		t.Errorf("bad edge attributes: %s", e)
+	}
+	e.SetColor(dot.Color{})
+	if e.Get("color") != "" {
+		t.Errorf("zero color is set: %s", e)
+	}
+
+	// colors are checked when they are made, labels when they are set
+	for _, s := range []string{"not a color!", "#12345", ""} {
+		if _, err := dot.ParseColor(s); !errors.Is(err, dot.AttributeError) {
+			t.Errorf("color %q: '%v' is not AttributeError", s, err)
+		}
+	}
+	if c, err := dot.ParseColor("/blues9/3"); err != nil || c.String() != "/blues9/3" {
+		t.Errorf("scheme color %q: %v", c, err)
+	}
+	if err := n.SetLabel("<<B>x</I>>"); !errors.Is(err, dot.AttributeError) {
+		t.Errorf("malformed HTML label: '%v' is not AttributeError", err)
+	}
+	if err := n.SetLabel("a < b"); err != nil || n.Get("label") != "a < b" {
+		t.Errorf("text label %q: %v", n.Get("label"), err)
+	}
+
+	cluster := dot.NewSubgraph("cluster0")
+	if err := cluster.SetColor(dot.RGBA(0, 0, 0, 128)); err != nil {
+		t.Error(err)
+	}
+	if err := cluster.SetFillColor(dot.MustParseColor("red")); err != nil {
+		t.Error(err)
+	}
+	if err := cluster.SetFillColor(dot.Color{}); err != nil || cluster.Get("fillcolor") != "" {
+		t.Errorf("zero fill color is set %q: %v", cluster.Get("fillcolor"), err)
+	}
+	if err := cluster.SetSortv(10); err != nil || cluster.Get("sortv") != "10" {
+		t.Errorf("bad sortv %q: %v", cluster.Get("sortv"), err)
+	}
+	if err := cluster.SetRank(dot.RankSame); err != nil {
+		t.Error(err)
+	}
+	if err := dot.NewGraph("G").SetColor(dot.MustParseColor("red")); !errors.Is(err, dot.AttributeError) {
+		t.Errorf("graph color: '%v' is not AttributeError", err)
+	}
+}
+
 func TestSubGraphs(t *testing.T) {
 	g := dot.NewGraph("G")
 	s := dot.NewSubgraph("SG")
@@ -149,3 +323,211 @@ func TestQuoting(t *testing.T) {
 	}
 
 }
+
+func TestWriteTo(t *testing.T) {
+	g := dot.NewGraph("G")
+	g.Set("label", "streamed")
+	sg := dot.NewSubgraph("cluster0")
+	sg.SetLabel("host-1")
+	a, b := dot.NewNode("a"), dot.NewNode("b")
+	sg.AddNode(a)
+	g.AddSubgraph(sg)
+	g.AddNode(b)
+	g.AddEdge(dot.NewEdge(a, b))
+	g.SameRank([]string{"a", "b"})
+
+	var buf strings.Builder
+	n, err := g.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != g.String() || n != int64(buf.Len()) {
+		t.Errorf("'%s' != '%s' (%d bytes)", buf.String(), g.String(), n)
+	}
+
+	// the first write error stops the output
+	w := &failingWriter{limit: 10}
+	if _, err = g.WriteTo(w); err != errWrite || w.written > 10 {
+		t.Errorf("write error is not returned: %v, %d bytes written", err, w.written)
+	}
+}
+
+var errWrite = errors.New("write failed")
+
+type failingWriter struct {
+	limit, written int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		return 0, errWrite
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestWriteFile(t *testing.T) {
+	g := dot.NewGraph("G")
+	g.AddEdge(dot.NewEdge(dot.NewNode("a"), dot.NewNode("b")))
+	dir := t.TempDir()
+
+	for _, compress := range []bool{false, true} {
+		path := filepath.Join(dir, fmt.Sprintf("g-%t.dot", compress))
+		if err := g.WriteFile(path, compress); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isGzip := len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b; isGzip != compress {
+			t.Errorf("compress %t: gzip %t", compress, isGzip)
+		}
+
+		parsed, err := dot.ParseFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.String() != g.String() {
+			t.Errorf("compress %t: '%s' != '%s'", compress, parsed, g)
+		}
+	}
+}
+
+func TestSortObjects(t *testing.T) {
+	build := func(names []string) *dot.Graph {
+		g := dot.NewGraph("G")
+		sg := dot.NewSubgraph("cluster0")
+		g.AddSubgraph(sg)
+		nodes := make(map[string]*dot.Node)
+		for _, name := range names {
+			nodes[name] = dot.NewNode(name)
+			nodes[name].Set("label", name)
+			nodes[name].Set("color", "red")
+			sg.AddNode(nodes[name])
+		}
+		for _, name := range names {
+			if name != "a" {
+				g.AddEdge(dot.NewEdge(nodes[name], nodes["a"]))
+			}
+		}
+		return g
+	}
+	// nodes by name, subgraphs before edges, edges by source
+	rank := func(o dot.GraphObject) (int, string) {
+		switch v := o.(type) {
+		case *dot.Node:
+			return 0, v.Name()
+		case *dot.SubGraph:
+			return 1, v.Name()
+		case *dot.Edge:
+			return 2, v.Source().Name()
+		}
+		return 3, ""
+	}
+	less := func(a, b dot.GraphObject) bool {
+		ra, na := rank(a)
+		rb, nb := rank(b)
+		if ra != rb {
+			return ra < rb
+		}
+		return na < nb
+	}
+
+	g1 := build([]string{"a", "b", "c"})
+	g2 := build([]string{"c", "a", "b"})
+	if g1.String() == g2.String() {
+		t.Fatal("insertion order is not kept")
+	}
+	g1.SortObjects(less)
+	g2.SortObjects(less)
+	if g1.String() != g2.String() {
+		t.Errorf("'%s' != '%s'", g1, g2)
+	}
+
+	// objects added after sorting go last
+	last := dot.NewNode("0")
+	g1.AddNode(last)
+	nodes := g1.Nodes()
+	if nodes[len(nodes)-1] != last {
+		t.Errorf("%s is not the last node", last.Name())
+	}
+}
+
+func TestHTMLLabels(t *testing.T) {
+	label, err := dot.HTMLLabel(dot.Table(
+		dot.Row(dot.Cell(dot.Bold(dot.Text("a & b"))).ColSpan(2)),
+		dot.Row(
+			dot.Cell(dot.Font(dot.Text("x<y\nz")).Color(dot.MustParseColor("red")).PointSize(8)).Port("p0"),
+			dot.Cell().Port("p1"),
+		),
+	).Border(0).CellBorder(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<<TABLE BORDER="0" CELLBORDER="1"><TR><TD COLSPAN="2"><B>a &amp; b</B></TD></TR>` +
+		`<TR><TD PORT="p0"><FONT COLOR="red" POINT-SIZE="8">x&lt;y<BR/>z</FONT></TD><TD PORT="p1"></TD></TR></TABLE>>`
+	if label != expected {
+		t.Errorf("'%s' != '%s'", label, expected)
+	}
+
+	// any label attribute takes the HTML string unquoted
+	g := dot.NewGraph("G")
+	a, b := dot.NewNode("a"), dot.NewNode("b")
+	g.AddNode(a)
+	g.AddNode(b)
+	e := dot.NewEdge(a, b)
+	g.AddEdge(e)
+	for _, err := range []error{
+		g.Set("label", "<<I>G</I>>"),
+		a.Set("label", label),
+		a.Set("xlabel", "<<B>x</B>>"),
+		e.Set("headlabel", "<h>"),
+		e.Set("tailport", "p0"),
+		e.Set("comment", "<not html>"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected = "digraph G {\ngraph [\n  label=<<I>G</I>>;\n];\n" +
+		"a [label=" + label + ", xlabel=<<B>x</B>>];\n" +
+		"b;\n" +
+		"a -> b  [ comment=\"<not html>\", headlabel=<h>, tailport=p0 ]\n}\n"
+	if g.String() != expected {
+		t.Errorf("'%s' != '%s'", g, expected)
+	}
+	parsed, err := dot.Parse(strings.NewReader(g.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != g.String() {
+		t.Errorf("'%s' != '%s'", parsed, g)
+	}
+
+	// a port with a compass point is not a single ID, it is quoted as a value
+	e.Set("tailport", "p0:s")
+	if !strings.Contains(g.String(), `tailport="p0:s"`) {
+		t.Errorf("'%s' has an unquoted port", g)
+	}
+	if parsed, err = dot.Parse(strings.NewReader(g.String())); err != nil || parsed.Edges()[0].Get("tailport") != "p0:s" {
+		t.Errorf("port is not parsed back: %v", err)
+	}
+
+	// misplaced elements and unknown attributes
+	for _, c := range []dot.HTMLContent{
+		dot.Row(dot.Cell()),
+		dot.Cell(),
+		dot.Table(dot.Row(dot.Row())),
+		dot.Table(dot.Row(dot.Cell().Attr("face", "x"))),
+	} {
+		if _, err := dot.HTMLLabel(c); !errors.Is(err, dot.AttributeError) {
+			t.Errorf("no error for %#v: %v", c, err)
+		}
+	}
+	for _, value := range []string{"<<B>x</I>>", "<<BLINK>x</BLINK>>", `<<FONT SIZE="2">x</FONT>>`} {
+		if err := a.Set("label", value); !errors.Is(err, dot.AttributeError) {
+			t.Errorf("no error for %s: %v", value, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package dot_test
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Fantom-foundation/dag2dot-tool/dot"
 )
@@ -32,3 +33,15 @@ func ExampleNewGraph() {
 	// }
 	//
 }
+
+func ExampleParse() {
+	g, err := dot.Parse(strings.NewReader(`digraph G { A -> B [dir=both] }`))
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range g.Edges() {
+		fmt.Println(e.Source().Name(), e.Destination().Name(), e.Get("dir"))
+	}
+	// Output:
+	// A B both
+}
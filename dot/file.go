@@ -0,0 +1,67 @@
+package dot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// fileBufferSize is the buffer size of the graph files
+const fileBufferSize = 64 * 1024
+
+// WriteFile streams the graph to the file through a buffer, gzip compressed if compress is set
+func (g *Graph) WriteFile(path string, compress bool) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	bw := bufio.NewWriterSize(f, fileBufferSize)
+	var w io.Writer = bw
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(bw)
+		w = zw
+	}
+
+	if _, err = g.WriteTo(w); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ParseFile parses the graph file, gzip compressed files are detected by the content
+func ParseFile(path string) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := OpenReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(r)
+}
+
+// OpenReader returns a reader of the plain graph text, gzip compressed input is decompressed
+func OpenReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, fileBufferSize)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
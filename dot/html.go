@@ -0,0 +1,231 @@
+package dot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// htmlAttributes are the attributes which take HTML-like labels,
+// a value in angle brackets "<...>" is written as an HTML string instead of a quoted one
+var htmlAttributes = []string{"headlabel", "label", "taillabel", "xlabel"}
+
+// htmlTags are the elements of the HTML-like labels and their attributes
+var htmlTags = map[string][]string{
+	"TABLE": {"ALIGN", "BGCOLOR", "BORDER", "CELLBORDER", "CELLPADDING", "CELLSPACING", "COLOR", "COLUMNS",
+		"FIXEDSIZE", "GRADIENTANGLE", "HEIGHT", "HREF", "ID", "PORT", "ROWS", "SIDES", "STYLE", "TARGET",
+		"TITLE", "TOOLTIP", "VALIGN", "WIDTH"},
+	"TD": {"ALIGN", "BALIGN", "BGCOLOR", "BORDER", "CELLPADDING", "CELLSPACING", "COLOR", "COLSPAN",
+		"FIXEDSIZE", "GRADIENTANGLE", "HEIGHT", "HREF", "ID", "PORT", "ROWSPAN", "SIDES", "STYLE", "TARGET",
+		"TITLE", "TOOLTIP", "VALIGN", "WIDTH"},
+	"TR":   {},
+	"FONT": {"COLOR", "FACE", "POINT-SIZE"},
+	"BR":   {"ALIGN"},
+	"IMG":  {"SCALE", "SRC"},
+	"HR":   {},
+	"VR":   {},
+	"B":    {},
+	"I":    {},
+	"U":    {},
+	"O":    {},
+	"S":    {},
+	"SUB":  {},
+	"SUP":  {},
+}
+
+// HTMLError is returned for a malformed HTML-like label. It wraps AttributeError.
+type HTMLError struct {
+	Reason string
+}
+
+func (e *HTMLError) Error() string {
+	return fmt.Sprintf("%s: HTML label: %s", AttributeError, e.Reason)
+}
+
+func (e *HTMLError) Unwrap() error {
+	return AttributeError
+}
+
+func isHTML(value string) bool {
+	return len(value) >= 2 && value[0] == '<' && value[len(value)-1] == '>'
+}
+
+// formatAttribute returns the attribute as written to the dot output
+func formatAttribute(name, value string) string {
+	if isHTML(value) && validAttribute(htmlAttributes, name) {
+		return name + "=" + value
+	}
+	return name + "=" + quoteValue(value)
+}
+
+// htmlType checks an HTML string: it is well-formed and uses the elements
+// and the attributes of the HTML-like labels only
+func htmlType(value string) string {
+	d := xml.NewDecoder(strings.NewReader("<html>" + value[1:len(value)-1] + "</html>"))
+	d.Entity = xml.HTMLEntity
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return ""
+		}
+		if err != nil {
+			return "is not a well-formed HTML label"
+		}
+		start, ok := t.(xml.StartElement)
+		if !ok || start.Name.Local == "html" {
+			continue
+		}
+		attrs, ok := htmlTags[strings.ToUpper(start.Name.Local)]
+		if !ok {
+			return fmt.Sprintf("has unknown element %s", start.Name.Local)
+		}
+		for _, attr := range start.Attr {
+			if indexInSlice(attrs, strings.ToUpper(attr.Name.Local)) < 0 {
+				return fmt.Sprintf("has unknown attribute %s of %s", attr.Name.Local, start.Name.Local)
+			}
+		}
+	}
+}
+
+// HTMLContent is the content of an HTML-like label: Text or an element
+type HTMLContent interface {
+	writeHTML(b *strings.Builder, parent string) error
+}
+
+// Text is the text of an HTML-like label, it is escaped and new lines become line breaks
+type Text string
+
+func (t Text) writeHTML(b *strings.Builder, parent string) error {
+	if parent == "TABLE" || parent == "TR" {
+		return &HTMLError{fmt.Sprintf("text %q in %s", string(t), parent)}
+	}
+	for i, line := range strings.Split(string(t), "\n") {
+		if i > 0 {
+			b.WriteString("<BR/>")
+		}
+		_ = xml.EscapeText(b, []byte(line))
+	}
+	return nil
+}
+
+// HTMLElement is an element of an HTML-like label, built by Table, Row, Cell, Font and the others
+type HTMLElement struct {
+	tag     string
+	attrs   [][2]string
+	content []HTMLContent
+}
+
+func element(tag string, content []HTMLContent) *HTMLElement {
+	return &HTMLElement{tag: tag, content: content}
+}
+
+// Table returns a table of the rows
+func Table(rows ...*HTMLElement) *HTMLElement {
+	content := make([]HTMLContent, len(rows))
+	for i, row := range rows {
+		content[i] = row
+	}
+	return element("TABLE", content)
+}
+
+// Row returns a table row of the cells
+func Row(cells ...*HTMLElement) *HTMLElement {
+	content := make([]HTMLContent, len(cells))
+	for i, cell := range cells {
+		content[i] = cell
+	}
+	return element("TR", content)
+}
+
+// Cell returns a table cell
+func Cell(content ...HTMLContent) *HTMLElement { return element("TD", content) }
+
+// Font returns a text of the font set by Face, PointSize and Color
+func Font(content ...HTMLContent) *HTMLElement { return element("FONT", content) }
+
+func Bold(content ...HTMLContent) *HTMLElement      { return element("B", content) }
+func Italic(content ...HTMLContent) *HTMLElement    { return element("I", content) }
+func Underline(content ...HTMLContent) *HTMLElement { return element("U", content) }
+func Break() *HTMLElement                           { return element("BR", nil) }
+
+// Attr sets an attribute of the element, it is checked when the label is built
+func (e *HTMLElement) Attr(name, value string) *HTMLElement {
+	name = strings.ToUpper(name)
+	for i := range e.attrs {
+		if e.attrs[i][0] == name {
+			e.attrs[i][1] = value
+			return e
+		}
+	}
+	e.attrs = append(e.attrs, [2]string{name, value})
+	return e
+}
+
+func (e *HTMLElement) Port(port string) *HTMLElement   { return e.Attr("PORT", port) }
+func (e *HTMLElement) ColSpan(n int) *HTMLElement      { return e.Attr("COLSPAN", strconv.Itoa(n)) }
+func (e *HTMLElement) Align(align string) *HTMLElement { return e.Attr("ALIGN", align) }
+func (e *HTMLElement) BgColor(c Color) *HTMLElement    { return e.Attr("BGCOLOR", c.String()) }
+func (e *HTMLElement) Color(c Color) *HTMLElement      { return e.Attr("COLOR", c.String()) }
+func (e *HTMLElement) Border(n int) *HTMLElement       { return e.Attr("BORDER", strconv.Itoa(n)) }
+func (e *HTMLElement) CellBorder(n int) *HTMLElement   { return e.Attr("CELLBORDER", strconv.Itoa(n)) }
+func (e *HTMLElement) CellSpacing(n int) *HTMLElement  { return e.Attr("CELLSPACING", strconv.Itoa(n)) }
+func (e *HTMLElement) CellPadding(n int) *HTMLElement  { return e.Attr("CELLPADDING", strconv.Itoa(n)) }
+func (e *HTMLElement) Face(face string) *HTMLElement   { return e.Attr("FACE", face) }
+func (e *HTMLElement) PointSize(size float64) *HTMLElement {
+	return e.Attr("POINT-SIZE", formatDouble(size))
+}
+
+// parentTags are the elements which may contain the element, any other element may contain the rest
+var parentTags = map[string]string{
+	"TR": "TABLE",
+	"TD": "TR",
+}
+
+func (e *HTMLElement) writeHTML(b *strings.Builder, parent string) error {
+	if want, ok := parentTags[e.tag]; ok && parent != want {
+		return &HTMLError{fmt.Sprintf("%s outside of %s", e.tag, want)}
+	}
+	switch {
+	case parent == "TABLE" && e.tag != "TR" && e.tag != "HR",
+		parent == "TR" && e.tag != "TD" && e.tag != "VR":
+		return &HTMLError{fmt.Sprintf("%s in %s", e.tag, parent)}
+	}
+
+	b.WriteString("<" + e.tag)
+	for _, attr := range e.attrs {
+		if indexInSlice(htmlTags[e.tag], attr[0]) < 0 {
+			return &HTMLError{fmt.Sprintf("unknown attribute %s of %s", attr[0], e.tag)}
+		}
+		b.WriteString(" " + attr[0] + "=\"")
+		_ = xml.EscapeText(b, []byte(attr[1]))
+		b.WriteString("\"")
+	}
+	if len(e.content) == 0 && e.tag != "TD" {
+		b.WriteString("/>")
+		return nil
+	}
+	b.WriteString(">")
+	for _, c := range e.content {
+		if err := c.writeHTML(b, e.tag); err != nil {
+			return err
+		}
+	}
+	b.WriteString("</" + e.tag + ">")
+	return nil
+}
+
+// HTMLLabel returns the HTML string of the content to set to any label attribute,
+// e.g. n.Set("label", l), it fails with HTMLError if the elements are misplaced.
+func HTMLLabel(content ...HTMLContent) (string, error) {
+	var b strings.Builder
+	b.WriteString("<")
+	for _, c := range content {
+		if err := c.writeHTML(&b, ""); err != nil {
+			return "", err
+		}
+	}
+	b.WriteString(">")
+	return b.String(), nil
+}
@@ -0,0 +1,554 @@
+package dot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var SyntaxError = errors.New("Syntax Error")
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenID
+	tokenHTML
+	tokenPunct
+	tokenEdgeOp
+)
+
+type token struct {
+	kind   tokenKind
+	value  string
+	quoted bool
+	line   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// isKeyword reports whether the token is the unquoted keyword, keywords are case-independent
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenID && !t.quoted && strings.EqualFold(t.value, keyword)
+}
+
+func (t token) is(kind tokenKind, value string) bool {
+	return t.kind == kind && t.value == value
+}
+
+func isIDChar(c byte) bool {
+	return c == '_' || c == '.' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+type lexer struct {
+	src    []byte
+	pos    int
+	line   int
+	tokens []token
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at line %d: %s", SyntaxError, l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) emit(kind tokenKind, value string, quoted bool, line int) {
+	l.tokens = append(l.tokens, token{kind: kind, value: value, quoted: quoted, line: line})
+}
+
+// skipSpace skips white spaces, comments and preprocessor lines
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#' && (l.pos == 0 || l.src[l.pos-1] == '\n'),
+			c == '/' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '/':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == '/' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '*':
+			end := strings.Index(string(l.src[l.pos+2:]), "*/")
+			if end < 0 {
+				return l.errorf("unterminated comment")
+			}
+			comment := l.src[l.pos : l.pos+2+end+2]
+			l.line += strings.Count(string(comment), "\n")
+			l.pos += len(comment)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// quoted reads a double-quoted string, the escapes are reverse to QuoteIfNecessary
+func (l *lexer) quoted() (string, error) {
+	var sb strings.Builder
+	l.pos++ // opening quote
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return sb.String(), nil
+		case c == '\\' && l.pos+1 < len(l.src):
+			next := l.src[l.pos+1]
+			switch next {
+			case '"':
+				sb.WriteByte('"')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case '\n':
+				// line continuation
+				l.line++
+			default:
+				sb.WriteByte(c)
+				sb.WriteByte(next)
+			}
+			l.pos += 2
+		default:
+			if c == '\n' {
+				l.line++
+			}
+			sb.WriteByte(c)
+			l.pos++
+		}
+	}
+	return "", l.errorf("unterminated string")
+}
+
+// html reads an HTML string with its outer angle brackets
+func (l *lexer) html() (string, error) {
+	start := l.pos
+	depth := 0
+	for ; l.pos < len(l.src); l.pos++ {
+		switch l.src[l.pos] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth == 0 {
+				l.pos++
+				return string(l.src[start:l.pos]), nil
+			}
+		case '\n':
+			l.line++
+		}
+	}
+	return "", l.errorf("unterminated HTML string")
+}
+
+func (l *lexer) run() error {
+	for {
+		if err := l.skipSpace(); err != nil {
+			return err
+		}
+		if l.pos >= len(l.src) {
+			l.emit(tokenEOF, "", false, l.line)
+			return nil
+		}
+
+		line := l.line
+		c := l.src[l.pos]
+		switch {
+		case strings.IndexByte("{}[]=;,:", c) >= 0:
+			l.emit(tokenPunct, string(c), false, line)
+			l.pos++
+		case c == '-' && l.pos+1 < len(l.src) && (l.src[l.pos+1] == '>' || l.src[l.pos+1] == '-'):
+			l.emit(tokenEdgeOp, string(l.src[l.pos:l.pos+2]), false, line)
+			l.pos += 2
+		case c == '"':
+			s, err := l.quoted()
+			if err != nil {
+				return err
+			}
+			// concatenation of quoted strings: "a" + "b"
+			for {
+				save, saveLine := l.pos, l.line
+				if err = l.skipSpace(); err != nil {
+					return err
+				}
+				if l.pos >= len(l.src) || l.src[l.pos] != '+' {
+					l.pos, l.line = save, saveLine
+					break
+				}
+				l.pos++
+				if err = l.skipSpace(); err != nil {
+					return err
+				}
+				if l.pos >= len(l.src) || l.src[l.pos] != '"' {
+					return l.errorf("quoted string expected after '+'")
+				}
+				next, err := l.quoted()
+				if err != nil {
+					return err
+				}
+				s += next
+			}
+			l.emit(tokenID, s, true, line)
+		case c == '<':
+			s, err := l.html()
+			if err != nil {
+				return err
+			}
+			l.emit(tokenHTML, s, false, line)
+		case c == '-' || isIDChar(c):
+			start := l.pos
+			l.pos++
+			for l.pos < len(l.src) && isIDChar(l.src[l.pos]) {
+				l.pos++
+			}
+			l.emit(tokenID, string(l.src[start:l.pos]), false, line)
+		default:
+			return l.errorf("unexpected character %q", c)
+		}
+	}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	root   *Graph
+	nodes  map[string]*Node
+	added  map[*Node]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%w at line %d: %s", SyntaxError, t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(value string) error {
+	t := p.next()
+	if !t.is(tokenPunct, value) {
+		return p.errorf(t, "%q expected, got %s", value, t)
+	}
+	return nil
+}
+
+// id reads an identifier, a numeral, a quoted or an HTML string
+func (p *parser) id() (string, error) {
+	t := p.next()
+	if t.kind != tokenID && t.kind != tokenHTML {
+		return "", p.errorf(t, "identifier expected, got %s", t)
+	}
+	return t.value, nil
+}
+
+func (p *parser) graph() error {
+	t := p.next()
+	if t.isKeyword("strict") {
+		p.root.strict = true
+		t = p.next()
+	}
+	switch {
+	case t.isKeyword("digraph"):
+		p.root.SetType(DIGRAPH)
+	case t.isKeyword("graph"):
+		p.root.SetType(GRAPH)
+	default:
+		return p.errorf(t, "graph or digraph expected, got %s", t)
+	}
+
+	if !p.peek().is(tokenPunct, "{") {
+		name, err := p.id()
+		if err != nil {
+			return err
+		}
+		p.root.name = name
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	if err := p.stmtList(p.root); err != nil {
+		return err
+	}
+	if t = p.next(); t.kind != tokenEOF {
+		return p.errorf(t, "end of input expected, got %s", t)
+	}
+	return nil
+}
+
+// stmtList reads statements into g until the closing brace
+func (p *parser) stmtList(g *Graph) error {
+	for {
+		t := p.peek()
+		switch {
+		case t.is(tokenPunct, "}"):
+			p.next()
+			return nil
+		case t.is(tokenPunct, ";"):
+			p.next()
+		case t.kind == tokenEOF:
+			return p.errorf(t, "\"}\" expected, got %s", t)
+		default:
+			if err := p.stmt(g); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *parser) stmt(g *Graph) error {
+	t := p.peek()
+	switch {
+	case t.isKeyword("graph"), t.isKeyword("node"), t.isKeyword("edge"):
+		p.next()
+		attrs, err := p.attrList()
+		if err != nil {
+			return err
+		}
+		attributes := g.attributes
+		if t.isKeyword("node") {
+			attributes = g.nodeAttributes
+		} else if t.isKeyword("edge") {
+			attributes = g.edgeAttributes
+		}
+		setAttributes(attributes, attrs)
+		return nil
+	case t.isKeyword("subgraph"), t.is(tokenPunct, "{"):
+		sg, err := p.subgraph()
+		if err != nil {
+			return err
+		}
+		if p.peek().kind == tokenEdgeOp {
+			return p.errorf(p.peek(), "subgraph as an edge end is not supported")
+		}
+		p.addSubgraph(g, sg)
+		return nil
+	case t.kind == tokenID || t.kind == tokenHTML:
+		name, err := p.nodeID()
+		if err != nil {
+			return err
+		}
+		switch next := p.peek(); {
+		case next.is(tokenPunct, "="):
+			p.next()
+			value, err := p.id()
+			if err != nil {
+				return err
+			}
+			setAttributes(g.attributes, [][2]string{{name, value}})
+			return nil
+		case next.kind == tokenEdgeOp:
+			return p.edgeStmt(g, name)
+		default:
+			return p.nodeStmt(g, name)
+		}
+	default:
+		return p.errorf(t, "statement expected, got %s", t)
+	}
+}
+
+// nodeID reads a node name with optional port and compass point
+func (p *parser) nodeID() (string, error) {
+	name, err := p.id()
+	if err != nil {
+		return "", err
+	}
+	for p.peek().is(tokenPunct, ":") {
+		p.next()
+		port, err := p.id()
+		if err != nil {
+			return "", err
+		}
+		name += ":" + port
+	}
+	return name, nil
+}
+
+func (p *parser) attrList() ([][2]string, error) {
+	attrs := make([][2]string, 0)
+	if !p.peek().is(tokenPunct, "[") {
+		return attrs, p.errorf(p.peek(), "\"[\" expected, got %s", p.peek())
+	}
+	for p.peek().is(tokenPunct, "[") {
+		p.next()
+		for !p.peek().is(tokenPunct, "]") {
+			key, err := p.id()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("="); err != nil {
+				return nil, err
+			}
+			value, err := p.id()
+			if err != nil {
+				return nil, err
+			}
+			attrs = append(attrs, [2]string{key, value})
+			if t := p.peek(); t.is(tokenPunct, ",") || t.is(tokenPunct, ";") {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	return attrs, nil
+}
+
+func (p *parser) optAttrList() ([][2]string, error) {
+	if !p.peek().is(tokenPunct, "[") {
+		return nil, nil
+	}
+	return p.attrList()
+}
+
+// setAttributes keeps the parsed attributes verbatim, they are not validated
+// as the attributes set by the code, so any graph is read as it was written
+func setAttributes(attributes map[string]string, attrs [][2]string) {
+	for _, attr := range attrs {
+		attributes[attr[0]] = attr[1]
+	}
+}
+
+// node returns the node with the name, a new node is not added to any graph
+func (p *parser) node(name string) *Node {
+	n, ok := p.nodes[name]
+	if !ok {
+		n = NewNode(name)
+		p.nodes[name] = n
+	}
+	return n
+}
+
+func (p *parser) nodeStmt(g *Graph, name string) error {
+	attrs, err := p.optAttrList()
+	if err != nil {
+		return err
+	}
+	n := p.node(name)
+	setAttributes(n.attributes, attrs)
+	if !p.added[n] {
+		p.added[n] = true
+		g.AddNode(n)
+	}
+	return nil
+}
+
+func (p *parser) edgeStmt(g *Graph, name string) error {
+	names := []string{name}
+	for p.peek().kind == tokenEdgeOp {
+		p.next()
+		if t := p.peek(); t.isKeyword("subgraph") || t.is(tokenPunct, "{") {
+			return p.errorf(t, "subgraph as an edge end is not supported")
+		}
+		next, err := p.nodeID()
+		if err != nil {
+			return err
+		}
+		names = append(names, next)
+	}
+
+	attrs, err := p.optAttrList()
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(names); i++ {
+		e := NewEdge(p.node(names[i-1]), p.node(names[i]))
+		setAttributes(e.attributes, attrs)
+		g.AddEdge(e)
+	}
+	return nil
+}
+
+func (p *parser) subgraph() (*SubGraph, error) {
+	name := ""
+	if p.peek().isKeyword("subgraph") {
+		p.next()
+		if !p.peek().is(tokenPunct, "{") {
+			var err error
+			if name, err = p.id(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	sg := NewSubgraph(name)
+	// edges are printed by the type of the root graph
+	sg.SetParentGraph(p.root)
+	if err := p.stmtList(&sg.Graph); err != nil {
+		return nil, err
+	}
+	return sg, nil
+}
+
+// addSubgraph adds sg to g, anonymous "rank=same" blocks go to the SameRank list
+func (p *parser) addSubgraph(g *Graph, sg *SubGraph) {
+	if sg.Name() != "" || sg.Get("rank") != "same" || len(sg.attributes) != 1 {
+		g.AddSubgraph(sg)
+		return
+	}
+
+	objects := make(graphObjects, 0)
+	for _, n := range sg.Nodes() {
+		objects = append(objects, n)
+	}
+	for _, e := range sg.Edges() {
+		objects = append(objects, e)
+	}
+	sort.Sort(objects)
+
+	statements := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		s := fmt.Sprint(obj)
+		if !strings.HasSuffix(s, ";") {
+			s += ";"
+		}
+		statements = append(statements, s)
+	}
+	g.SameRank(statements)
+}
+
+// Parse reads a graph in the dot language.
+// Nodes which are only referred by edges are not added to the graph.
+// The attributes are kept as they are written, without validation.
+func Parse(r io.Reader) (*Graph, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &lexer{src: src, line: 1}
+	if err = l.run(); err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens: l.tokens,
+		root:   NewGraph(""),
+		nodes:  make(map[string]*Node),
+		added:  make(map[*Node]bool),
+	}
+	if err = p.graph(); err != nil {
+		return nil, err
+	}
+	return p.root, nil
+}
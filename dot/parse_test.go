@@ -0,0 +1,140 @@
+package dot_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	g := dot.NewGraph("DAG1")
+	g.Set("compound", "true")
+	g.Set("ranksep", "0.05")
+
+	sg := dot.NewSubgraph("cluster0")
+	sg.Set("label", "host-1")
+	sg.Set("style", "dotted")
+	a, b := dot.NewNode("a\n1-2"), dot.NewNode("b\n1-1")
+	a.Set("shape", "tripleoctagon")
+	b.Set("label", "<<B>b</B>>")
+	sg.AddNode(a)
+	sg.AddNode(b)
+	e := dot.NewEdge(a, b)
+	e.Set("constraint", "true")
+	sg.AddEdge(e)
+	g.AddSubgraph(sg)
+
+	c := dot.NewNode("c")
+	g.AddNode(c)
+	g.AddEdge(dot.NewEdge(c, a))
+	g.SameRank([]string{`"host-1" -> "host-2" [style = invis, constraint = true];`})
+
+	parsed, err := dot.Parse(strings.NewReader(g.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Replace(g.String(),
+		`"host-1" -> "host-2" [style = invis, constraint = true];`,
+		`"host-1" -> "host-2"  [ constraint=true, style=invis ];`, 1)
+	if parsed.String() != expected {
+		t.Errorf("'%s' != '%s'", parsed, expected)
+	}
+}
+
+func TestParseStatements(t *testing.T) {
+	src := `/* comment */
+strict graph "my graph" {
+	// line comment
+	graph [label="multi\nline" + " label"];
+	node [shape=box]; edge [color=red]
+	rankdir=LR
+	a -- b -- c [weight=2];
+	"x y":p1 [label=<<I>x</I>>];
+	subgraph { d }
+}
+`
+	g, err := dot.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Name() != "my graph" {
+		t.Errorf("'%s' != '%s'", g.Name(), "my graph")
+	}
+	if g.Get("label") != "multi\nline label" {
+		t.Errorf("'%s' != '%s'", g.Get("label"), "multi\nline label")
+	}
+	if g.Get("rankdir") != "LR" {
+		t.Errorf("'%s' != '%s'", g.Get("rankdir"), "LR")
+	}
+
+	edges := g.Edges()
+	if len(edges) != 2 {
+		t.Fatalf("%d edges parsed instead of 2", len(edges))
+	}
+	if edges[1].Source() != edges[0].Destination() || edges[1].Get("weight") != "2" {
+		t.Errorf("bad edge chain: %s, %s", edges[0], edges[1])
+	}
+
+	nodes := g.Nodes()
+	if len(nodes) != 1 || nodes[0].Name() != "x y:p1" || nodes[0].Get("label") != "<<I>x</I>>" {
+		t.Errorf("bad nodes: %v", nodes)
+	}
+	if sgs := g.GetSubgraphs(); len(sgs) != 1 || len(sgs[0].Nodes()) != 1 {
+		t.Errorf("bad anonymous subgraph: %v", sgs)
+	}
+}
+
+func TestParseVerbatim(t *testing.T) {
+	cases := map[string]string{
+		// attributes are not validated
+		"digraph G { a [class=foo] }":            "digraph G {\na [class=foo];\n}\n",
+		"digraph G { subgraph s { color=red } }": "digraph G {\nsubgraph s {\ngraph [\n  color=red;\n];\n}\n\n}\n",
+		// anonymous graph keeps its keyword
+		"digraph { a -> b }": "digraph {\na -> b\n}\n",
+		"graph { a -- b }":   "graph {\na -- b\n}\n",
+		// HTML-like ID keeps its form
+		"digraph G { <a> -> b; <a> [shape=box] }": "digraph G {\n<a> -> b\n<a> [shape=box];\n}\n",
+	}
+
+	for src, expected := range cases {
+		g, err := dot.Parse(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("%q: %v", src, err)
+			continue
+		}
+		if g.String() != expected {
+			t.Errorf("%q: '%s' != '%s'", src, g, expected)
+		}
+		again, err := dot.Parse(strings.NewReader(g.String()))
+		if err != nil || again.String() != expected {
+			t.Errorf("%q: not stable: '%s', %v", src, again, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]error{
+		"digraph G { a -> }":               dot.SyntaxError,
+		"digraph G { a [label=\"x] }":      dot.SyntaxError,
+		"tree G {}":                        dot.SyntaxError,
+		"digraph G { a } b":                dot.SyntaxError,
+		"digraph G {\n\n a -> {b c} }":     dot.SyntaxError,
+		"digraph G { subgraph s {} -> a }": dot.SyntaxError,
+	}
+
+	for src, expected := range cases {
+		_, err := dot.Parse(strings.NewReader(src))
+		if !errors.Is(err, expected) {
+			t.Errorf("%q: '%v' is not '%v'", src, err, expected)
+		}
+	}
+
+	_, err := dot.Parse(strings.NewReader("digraph G {\n\n a -> }"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("line is not reported: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package dot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Color is a Graphviz color: a color name like "red" or "#RRGGBB".
+// It is made by RGB, RGBA or ParseColor only, so a set color is always valid.
+// The zero Color is no color, setting it removes the attribute.
+type Color struct {
+	value string
+}
+
+// RGB returns the color of the components
+func RGB(r, g, b uint8) Color {
+	return Color{fmt.Sprintf("#%02X%02X%02X", r, g, b)}
+}
+
+// RGBA returns the color of the components with transparency
+func RGBA(r, g, b, a uint8) Color {
+	return Color{fmt.Sprintf("#%02X%02X%02X%02X", r, g, b, a)}
+}
+
+// ParseColor checks a color name or a "#RRGGBB[AA]" color, it returns AttributeError if it is not a color
+func ParseColor(s string) (Color, error) {
+	if reason := colorType(s); reason != "" {
+		return Color{}, fmt.Errorf("%w: %q %s", AttributeError, s, reason)
+	}
+	return Color{strings.TrimSpace(s)}, nil
+}
+
+// MustParseColor is ParseColor for the color literals, it panics if s is not a color
+func MustParseColor(s string) Color {
+	c, err := ParseColor(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c Color) String() string {
+	return c.value
+}
+
+// setColor sets the color attribute, the zero color removes it
+func setColor(attributes map[string]string, name string, c Color) {
+	if c.value == "" {
+		delete(attributes, name)
+		return
+	}
+	attributes[name] = c.value
+}
+
+// Shape is a node shape
+type Shape string
+
+const (
+	ShapeBox           Shape = "box"
+	ShapeEllipse       Shape = "ellipse"
+	ShapeCircle        Shape = "circle"
+	ShapePoint         Shape = "point"
+	ShapeNote          Shape = "note"
+	ShapePlain         Shape = "plain"
+	ShapePlaintext     Shape = "plaintext"
+	ShapeRecord        Shape = "record"
+	ShapeOctagon       Shape = "octagon"
+	ShapeDoubleOctagon Shape = "doubleoctagon"
+	ShapeTripleOctagon Shape = "tripleoctagon"
+)
+
+// Style is a set of styles combined with "|"
+type Style uint16
+
+const (
+	StyleSolid Style = 1 << iota
+	StyleDashed
+	StyleDotted
+	StyleBold
+	StyleInvis
+	StyleFilled
+	StyleRounded
+	StyleDiagonals
+	StyleStriped
+	StyleWedged
+	StyleRadial
+	StyleTapered
+)
+
+var styleNames = []string{"solid", "dashed", "dotted", "bold", "invis", "filled",
+	"rounded", "diagonals", "striped", "wedged", "radial", "tapered"}
+
+// String returns the comma separated styles of the set
+func (s Style) String() string {
+	names := make([]string, 0, 2)
+	for i, name := range styleNames {
+		if s&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// Rank is a rank constraint of the subgraph nodes
+type Rank string
+
+const (
+	RankSame   Rank = "same"
+	RankMin    Rank = "min"
+	RankSource Rank = "source"
+	RankMax    Rank = "max"
+	RankSink   Rank = "sink"
+)
+
+func formatDouble(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Typed setters of the nodes and edges can not fail, the attributes are valid
+// for the objects and the values are formatted from the types. Set is the
+// escape hatch for the other attributes.
+
+func (n *Node) SetColor(c Color)         { setColor(n.attributes, "color", c) }
+func (n *Node) SetFillColor(c Color)     { setColor(n.attributes, "fillcolor", c) }
+func (n *Node) SetFontColor(c Color)     { setColor(n.attributes, "fontcolor", c) }
+func (n *Node) SetShape(s Shape)         { n.attributes["shape"] = string(s) }
+func (n *Node) SetStyle(s Style)         { n.attributes["style"] = s.String() }
+func (n *Node) SetPenWidth(w float64)    { n.attributes["penwidth"] = formatDouble(w) }
+func (n *Node) SetFontSize(size float64) { n.attributes["fontsize"] = formatDouble(size) }
+
+// SetLabel sets the text or the HTML label, it returns AttributeError for a malformed HTML label
+func (n *Node) SetLabel(label string) error { return n.Set("label", label) }
+
+func (e *Edge) SetColor(c Color)      { setColor(e.attributes, "color", c) }
+func (e *Edge) SetStyle(s Style)      { e.attributes["style"] = s.String() }
+func (e *Edge) SetPenWidth(w float64) { e.attributes["penwidth"] = formatDouble(w) }
+func (e *Edge) SetConstraint(c bool)  { e.attributes["constraint"] = strconv.FormatBool(c) }
+
+// Typed setters of the graphs return AttributeError if the attribute is not
+// used by the kind of the graph, e.g. colors are only for the clusters.
+
+func (g *Graph) SetLabel(label string) error { return g.Set("label", label) }
+func (g *Graph) SetStyle(s Style) error      { return g.Set("style", s.String()) }
+func (g *Graph) SetColor(c Color) error      { return g.setColor("color", c) }
+func (g *Graph) SetFillColor(c Color) error  { return g.setColor("fillcolor", c) }
+func (g *Graph) SetPenWidth(w float64) error { return g.Set("penwidth", formatDouble(w)) }
+func (g *Graph) SetRank(r Rank) error        { return g.Set("rank", string(r)) }
+func (g *Graph) SetSortv(v int) error        { return g.Set("sortv", strconv.Itoa(v)) }
+
+// setColor sets the color attribute of the graph, the zero color removes it
+func (g *Graph) setColor(name string, c Color) error {
+	if c.value == "" {
+		delete(g.attributes, name)
+		return nil
+	}
+	return g.Set(name, c.value)
+}
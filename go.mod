@@ -7,6 +7,7 @@ require (
 	github.com/Fantom-foundation/lachesis-base v0.0.0-20230817040848-1326ba9aa59b
 	github.com/ethereum/go-ethereum v1.10.8
 	github.com/golang-collections/collections v0.0.0-20130729185459-604e922904d3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
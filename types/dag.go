@@ -0,0 +1,248 @@
+package types
+
+import (
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+)
+
+// Dag is the DAG captured so far, it is kept across the capture loops.
+// Every event gets its graph node and every parent edge its graph edge once,
+// the graph of a capture loop is a View built of them, so a loop builds the
+// objects of the new events only. The changes of every snapshot are tracked
+// exactly by event IDs and parent edges.
+// The graph and its clusters are light containers made by every loop, the
+// nodes are shared by the graphs of an epoch, so a graph is to be written
+// before the next view of its epoch is built.
+type Dag struct {
+	events    map[hash.Event]*dagEvent
+	edges     map[dagEdge]*dagEdgeObject
+	views     int
+	snapshots int
+}
+
+type dagEvent struct {
+	event *EventNode
+	node  dagObject // node of the event
+	stub  dagObject // node of the event outside the window
+	root  bool      // root status the node was built with
+	shown bool      // the event was in a snapshot
+	// root status in the last snapshot with the event
+	shownRoot bool
+}
+
+type dagEdgeObject struct {
+	edge  dagObject
+	shown bool // the edge was in a snapshot
+}
+
+// attributed is a graph node or edge
+type attributed interface {
+	Attributes() map[string]string
+	ResetAttributes(map[string]string)
+}
+
+// dagObject is a node or an edge built once, with the attributes it was built with
+type dagObject struct {
+	object     attributed
+	attributes map[string]string
+	taken      int // view which took the object last
+}
+
+// take restores the attributes when the object is taken by a view for the first time
+func (o *dagObject) take(view int) bool {
+	if o.taken == view {
+		return false
+	}
+	o.taken = view
+	o.object.ResetAttributes(o.attributes)
+	return true
+}
+
+// set keeps the built object, it is taken by the view
+func (o *dagObject) set(object attributed, view int) {
+	o.object = object
+	o.attributes = object.Attributes()
+	o.taken = view
+}
+
+// dagEdge is an edge from the event to its parent
+type dagEdge struct {
+	event, parent hash.Event
+}
+
+// NewDag returns an empty DAG
+func NewDag() *Dag {
+	return &Dag{
+		events: make(map[hash.Event]*dagEvent),
+		edges:  make(map[dagEdge]*dagEdgeObject),
+	}
+}
+
+// Add adds the event, it returns false if the event is known already
+func (d *Dag) Add(e *EventNode) bool {
+	if _, ok := d.events[e.ID()]; ok {
+		return false
+	}
+	d.events[e.ID()] = &dagEvent{event: e}
+	return true
+}
+
+// Event returns the added event, nil if it is unknown
+func (d *Dag) Event(h hash.Event) *EventNode {
+	if e, ok := d.events[h]; ok {
+		return e.event
+	}
+	return nil
+}
+
+// Prune forgets the events and the edges of the epochs before the given one
+func (d *Dag) Prune(before idx.Epoch) {
+	for h := range d.events {
+		if h.Epoch() < before {
+			delete(d.events, h)
+		}
+	}
+	for e := range d.edges {
+		if e.event.Epoch() < before {
+			delete(d.edges, e)
+		}
+	}
+}
+
+// View starts the view of the next snapshot
+func (d *Dag) View() *View {
+	d.views++
+	return &View{
+		dag:    d,
+		id:     d.views,
+		events: make(map[hash.Event]bool),
+	}
+}
+
+// View is the graph objects taken by a capture loop
+type View struct {
+	dag    *Dag
+	id     int
+	events map[hash.Event]bool // events taken as nodes
+	order  hash.Events
+	edges  []dagEdge
+}
+
+// event returns the state of the event, the event is added if unknown
+func (v *View) event(e *EventNode) *dagEvent {
+	v.dag.Add(e)
+	return v.dag.events[e.ID()]
+}
+
+// Node returns the graph node of the event. It is built once and every view
+// takes it with the attributes it was built with, so the marks of the previous
+// views are dropped. It is built again if the root status of the event changed,
+// as the label may show it.
+func (v *View) Node(e *EventNode, build func(*EventNode) *dot.Node) *dot.Node {
+	ev := v.event(e)
+	switch {
+	case ev.node.object == nil:
+		ev.node.set(build(ev.event), v.id)
+		ev.root = ev.event.IsRoot
+	case ev.root != ev.event.IsRoot:
+		n := ev.node.object.(*dot.Node)
+		n.ResetAttributes(build(ev.event).Attributes())
+		ev.node.set(n, v.id)
+		ev.root = ev.event.IsRoot
+	default:
+		ev.node.take(v.id)
+	}
+	if !v.events[e.ID()] {
+		v.events[e.ID()] = true
+		v.order = append(v.order, e.ID())
+	}
+	return ev.node.object.(*dot.Node)
+}
+
+// Stub returns the boundary stub node of the event outside the window, it is built once as the nodes
+func (v *View) Stub(e *EventNode, build func(*EventNode) *dot.Node) *dot.Node {
+	ev := v.event(e)
+	if ev.stub.object == nil {
+		ev.stub.set(build(ev.event), v.id)
+	} else {
+		ev.stub.take(v.id)
+	}
+	return ev.stub.object.(*dot.Node)
+}
+
+// Edge returns the graph edge from the event to its parent, it is built once as the nodes
+func (v *View) Edge(e, parent *EventNode, build func() *dot.Edge) *dot.Edge {
+	key := dagEdge{e.ID(), parent.ID()}
+	o, ok := v.dag.edges[key]
+	if !ok {
+		o = &dagEdgeObject{}
+		o.edge.set(build(), v.id)
+		v.dag.edges[key] = o
+		v.edges = append(v.edges, key)
+	} else if o.edge.take(v.id) {
+		v.edges = append(v.edges, key)
+	}
+	return o.edge.object.(*dot.Edge)
+}
+
+// Snapshot returns the changes of the view since the previous snapshot.
+// An event or an edge between the events is new when it is shown for the
+// first time, e.g. an old event gets a new edge when its parent comes into
+// the view. The first snapshot has no changes.
+func (v *View) Snapshot() *Snapshot {
+	d := v.dag
+	s := &Snapshot{
+		status: make(map[hash.Event]string),
+		edges:  make(map[dagEdge]string),
+	}
+	for _, h := range v.order {
+		e, ok := d.events[h]
+		if !ok {
+			continue
+		}
+		root := e.event.IsRoot
+		switch {
+		case d.snapshots == 0:
+		case !e.shown:
+			s.status[h] = StatusNew
+		case e.shownRoot != root:
+			if root {
+				s.status[h] = StatusNewRoot
+			} else {
+				s.status[h] = StatusOldRoot
+			}
+		}
+		e.shown = true
+		e.shownRoot = root
+	}
+	for _, key := range v.edges {
+		o, ok := d.edges[key]
+		if !ok || !v.events[key.event] || !v.events[key.parent] {
+			continue
+		}
+		if d.snapshots > 0 && !o.shown {
+			s.edges[key] = StatusNew
+		}
+		o.shown = true
+	}
+	d.snapshots++
+	return s
+}
+
+// Snapshot is the changes of a view since the previous snapshot
+type Snapshot struct {
+	status map[hash.Event]string
+	edges  map[dagEdge]string
+}
+
+// Status returns the change status of the event, "" if unchanged
+func (s *Snapshot) Status(h hash.Event) string {
+	return s.status[h]
+}
+
+// EdgeStatus returns the change status of the edge from the event to its parent, "" if unchanged
+func (s *Snapshot) EdgeStatus(event, parent hash.Event) string {
+	return s.edges[dagEdge{event, parent}]
+}
@@ -0,0 +1,277 @@
+package types
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+)
+
+// GraphEvent is an event read back from a DAG graph
+type GraphEvent struct {
+	ID      string
+	Frame   string
+	Group   string
+	Parents []string
+	Node    *dot.Node
+}
+
+// GraphEvents collects the events of a graph produced by dot-tool.
+// Event nodes are named by the full event hash and keep the frame in the comment,
+// older captures named them "{id}\n{frame}-{seq}". Edges go from an event to its parents.
+func GraphEvents(g *dot.Graph) map[string]*GraphEvent {
+	events := make(map[string]*GraphEvent)
+	collectEvents(g, "", events)
+	collectParents(g, events)
+	for _, e := range events {
+		sort.Strings(e.Parents)
+	}
+	return events
+}
+
+// eventID returns the event ID of the node name
+func eventID(nodeName string) (id string, ok bool) {
+	if len(nodeName) == 66 && strings.HasPrefix(nodeName, "0x") {
+		return nodeName, true
+	}
+	lines := strings.SplitN(nodeName, "\n", 2)
+	if len(lines) != 2 {
+		return "", false
+	}
+	return lines[0], true
+}
+
+// eventFrame returns the frame of the event node
+func eventFrame(n *dot.Node) string {
+	if comment := n.Get("comment"); strings.HasPrefix(comment, "frame ") {
+		return strings.SplitN(strings.TrimPrefix(comment, "frame "), ",", 2)[0]
+	}
+	lines := strings.SplitN(n.Name(), "\n", 2)
+	if len(lines) != 2 {
+		return ""
+	}
+	return strings.SplitN(lines[1], "-", 2)[0]
+}
+
+func collectEvents(g *dot.Graph, group string, events map[string]*GraphEvent) {
+	for _, n := range g.Nodes() {
+		id, ok := eventID(n.Name())
+		if !ok {
+			continue
+		}
+		events[id] = &GraphEvent{
+			ID:    id,
+			Frame: eventFrame(n),
+			Group: group,
+			Node:  n,
+		}
+	}
+	for _, sg := range g.GetSubgraphs() {
+		collectEvents(&sg.Graph, groupKey(sg.Get("label")), events)
+	}
+}
+
+// groupKey returns the group of the cluster label, it is the first word of the label, e.g. "host-1".
+// The rest of the label is the details which may differ between the captures,
+// like the stake of the validator or the " (cheater)" mark.
+func groupKey(label string) string {
+	line := strings.SplitN(label, "\n", 2)[0]
+	if fields := strings.Fields(line); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func collectParents(g *dot.Graph, events map[string]*GraphEvent) {
+	for _, e := range g.Edges() {
+		id, ok := eventID(e.Source().Name())
+		if !ok {
+			continue
+		}
+		parent, ok := eventID(e.Destination().Name())
+		if !ok {
+			continue
+		}
+		if ev, ok := events[id]; ok {
+			ev.Parents = append(ev.Parents, parent)
+		}
+	}
+	for _, sg := range g.GetSubgraphs() {
+		collectParents(&sg.Graph, events)
+	}
+}
+
+// DagDiff is a structural difference of two DAG graphs compared by event IDs
+type DagDiff struct {
+	Left, Right map[string]*GraphEvent
+	OnlyLeft    []string
+	OnlyRight   []string
+	Frames      []string
+	Parents     []string
+}
+
+// DiffGraphs compares the events of two graphs
+func DiffGraphs(left, right *dot.Graph) *DagDiff {
+	d := &DagDiff{
+		Left:  GraphEvents(left),
+		Right: GraphEvents(right),
+	}
+
+	for id, l := range d.Left {
+		r, ok := d.Right[id]
+		if !ok {
+			d.OnlyLeft = append(d.OnlyLeft, id)
+			continue
+		}
+		if l.Frame != r.Frame {
+			d.Frames = append(d.Frames, id)
+		}
+		if strings.Join(l.Parents, ",") != strings.Join(r.Parents, ",") {
+			d.Parents = append(d.Parents, id)
+		}
+	}
+	for id := range d.Right {
+		if _, ok := d.Left[id]; !ok {
+			d.OnlyRight = append(d.OnlyRight, id)
+		}
+	}
+
+	sort.Strings(d.OnlyLeft)
+	sort.Strings(d.OnlyRight)
+	sort.Strings(d.Frames)
+	sort.Strings(d.Parents)
+	return d
+}
+
+// Empty is true if the graphs have the same events
+func (d *DagDiff) Empty() bool {
+	return len(d.OnlyLeft)+len(d.OnlyRight)+len(d.Frames)+len(d.Parents) == 0
+}
+
+// MergedGraph builds the union of both DAGs with the differences colored:
+// events and parent edges of one side only get leftColor or rightColor,
+// events with different frames or parents get changedColor.
+// It returns the error of an attribute which can not be set.
+func (d *DagDiff) MergedGraph(name string, leftColor, rightColor, changedColor dot.Color) (*dot.Graph, error) {
+	g := dot.NewGraph(name)
+	err := firstError(
+		g.Set("clusterrank", "local"),
+		g.Set("compound", "true"),
+		g.Set("newrank", "true"),
+		g.Set("ranksep", "0.05"))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(d.Left)+len(d.OnlyRight))
+	for id := range d.Left {
+		ids = append(ids, id)
+	}
+	ids = append(ids, d.OnlyRight...)
+	sort.Strings(ids)
+
+	subGraphs := make(map[string]*dot.SubGraph)
+	nodes := make(map[string]*dot.Node)
+	for _, id := range ids {
+		ev, ok := d.Left[id]
+		var color dot.Color
+		if !ok {
+			ev = d.Right[id]
+			color = rightColor
+		} else if _, ok = d.Right[id]; !ok {
+			color = leftColor
+		}
+
+		n := dot.NewNode(ev.Node.Name())
+		for _, attr := range []string{"label", "comment"} {
+			if v := ev.Node.Get(attr); v != "" {
+				if err = n.Set(attr, v); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if color != (dot.Color{}) {
+			n.SetColor(color)
+			n.SetPenWidth(2.5)
+		}
+		nodes[id] = n
+
+		sg, ok := subGraphs[ev.Group]
+		if !ok {
+			sg = dot.NewSubgraph("cluster" + ev.Group)
+			if err = sg.SetLabel(ev.Group); err != nil {
+				return nil, err
+			}
+			if err = sg.SetStyle(dot.StyleDotted); err != nil {
+				return nil, err
+			}
+			subGraphs[ev.Group] = sg
+		}
+		sg.AddNode(n)
+	}
+	for _, id := range append(append([]string{}, d.Frames...), d.Parents...) {
+		nodes[id].SetStyle(dot.StyleFilled)
+		nodes[id].SetFillColor(changedColor)
+	}
+
+	groups := make([]string, 0, len(subGraphs))
+	for group := range subGraphs {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		g.AddSubgraph(subGraphs[group])
+	}
+
+	for _, id := range ids {
+		parents := make(map[string]dot.Color)
+		if l, ok := d.Left[id]; ok {
+			for _, p := range l.Parents {
+				parents[p] = leftColor
+			}
+		}
+		if r, ok := d.Right[id]; ok {
+			for _, p := range r.Parents {
+				if _, ok := parents[p]; ok {
+					parents[p] = dot.Color{}
+				} else {
+					parents[p] = rightColor
+				}
+			}
+		}
+
+		for _, p := range sortedParents(parents) {
+			parent, ok := nodes[p]
+			if !ok {
+				continue
+			}
+			e := dot.NewEdge(nodes[id], parent)
+			if color := parents[p]; color != (dot.Color{}) {
+				e.SetColor(color)
+				e.SetPenWidth(2.5)
+			}
+			g.AddEdge(e)
+		}
+	}
+
+	return g, nil
+}
+
+func sortedParents(parents map[string]dot.Color) []string {
+	res := make([]string, 0, len(parents))
+	for p := range parents {
+		res = append(res, p)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// firstError returns the first error which is not nil
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+)
+
+// diffID returns the full event hash of the node names
+func diffID(c string) string {
+	return "0x" + strings.Repeat(c, 64)
+}
+
+func parseDiffGraph(t *testing.T, src string) *dot.Graph {
+	t.Helper()
+	src = strings.NewReplacer("A", diffID("a"), "B", diffID("b"), "C", diffID("c"), "D", diffID("d")).Replace(src)
+	g, err := dot.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestDiffGraphs(t *testing.T) {
+	left := parseDiffGraph(t, `digraph L {
+subgraph cluster1 {
+graph [label="host-1\n10 FTM (50.00%)"];
+"A" [comment="frame 1, lamport 1"];
+"B" [comment="frame 2, lamport 2"];
+}
+subgraph cluster2 {
+graph [label="host-2"];
+"C" [comment="frame 1, lamport 1"];
+}
+"B" -> "A"
+"B" -> "C"
+}`)
+	right := parseDiffGraph(t, `digraph R {
+subgraph cluster1 {
+graph [label="host-1\n20 FTM (66.67%) (cheater)"];
+"A" [comment="frame 1, lamport 1"];
+"B" [comment="frame 3, lamport 2"];
+}
+subgraph cluster3 {
+graph [label="host-3"];
+"D" [comment="frame 1, lamport 1"];
+}
+"B" -> "A"
+"B" -> "D"
+}`)
+
+	d := DiffGraphs(left, right)
+	check := func(what string, got []string, expected ...string) {
+		if strings.Join(got, " ") != strings.Join(expected, " ") {
+			t.Errorf("%s: %v != %v", what, got, expected)
+		}
+	}
+	check("only left", d.OnlyLeft, diffID("c"))
+	check("only right", d.OnlyRight, diffID("d"))
+	check("frames", d.Frames, diffID("b"))
+	check("parents", d.Parents, diffID("b"))
+	check("left parents", d.Left[diffID("b")].Parents, diffID("a"), diffID("c"))
+	check("right parents", d.Right[diffID("b")].Parents, diffID("a"), diffID("d"))
+	if d.Empty() {
+		t.Error("different graphs are the same")
+	}
+	// the groups are the same, though the details of the labels differ
+	if l, r := d.Left[diffID("b")].Group, d.Right[diffID("b")].Group; l != "host-1" || r != "host-1" {
+		t.Errorf("bad groups %q, %q", l, r)
+	}
+	if !DiffGraphs(left, left).Empty() {
+		t.Error("the same graph differs")
+	}
+
+	blue, red, changed := dot.MustParseColor("blue"), dot.MustParseColor("red"), dot.RGB(0xFF, 0xAA, 0xAA)
+	g, err := d.MergedGraph("DIFF", blue, red, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := make([]string, 0)
+	for _, sg := range g.GetSubgraphs() {
+		groups = append(groups, sg.Get("label"))
+	}
+	check("merged groups", groups, "host-1", "host-2", "host-3")
+
+	merged := GraphEvents(g)
+	if len(merged) != 4 {
+		t.Fatalf("%d events are merged instead of 4", len(merged))
+	}
+	for c, color := range map[string]string{"a": "", "c": "blue", "d": "red"} {
+		if n := merged[diffID(c)].Node; n.Get("color") != color {
+			t.Errorf("event %s: color %q != %q", c, n.Get("color"), color)
+		}
+	}
+	if n := merged[diffID("b")].Node; n.Get("fillcolor") != changed.String() {
+		t.Errorf("changed event is not filled: %s", n)
+	}
+	edges := make(map[string]string)
+	for _, e := range g.Edges() {
+		edges[e.Destination().Name()] = e.Get("color")
+	}
+	for c, color := range map[string]string{"a": "", "c": "blue", "d": "red"} {
+		if got, ok := edges[diffID(c)]; !ok || got != color {
+			t.Errorf("edge to %s: color %q != %q", c, got, color)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package types
+
+import (
+	"sort"
+
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+)
+
+// DagJSONVersion is the version of the DagJSON schema.
+// It is increased on every incompatible change of the schema.
+const DagJSONVersion = 1
+
+// DagJSON is the JSON export of a captured DAG
+type DagJSON struct {
+	// Version of the schema, DagJSONVersion
+	Version int `json:"version"`
+	// Name of the graph, the same as the name of the DOT graph
+	Name   string      `json:"name"`
+	Events []EventJSON `json:"events"`
+}
+
+// EventJSON is an event of the JSON export
+type EventJSON struct {
+	// ID is the full event hash in hex
+	ID      string          `json:"id"`
+	Creator idx.ValidatorID `json:"creator"`
+	Epoch   idx.Epoch       `json:"epoch"`
+	Seq     idx.Event       `json:"seq"`
+	Frame   idx.Frame       `json:"frame"`
+	Lamport idx.Lamport     `json:"lamport"`
+	// Parents are the full parent hashes, the self-parent goes first
+	Parents []string `json:"parents"`
+	// CreationTime and MedianTime are unix times in nanoseconds
+	CreationTime uint64 `json:"creationTime"`
+	MedianTime   uint64 `json:"medianTime"`
+	// TxCount is the count of transactions, omitted if the payload is not fetched
+	TxCount *int `json:"txCount,omitempty"`
+	Root    bool `json:"root"`
+	// Status is the change relative to the previous graph: StatusNew, StatusNewRoot,
+	// StatusOldRoot or omitted if unchanged
+	Status string `json:"status,omitempty"`
+}
+
+// NewEventJSON exports the event with its change status
+func NewEventJSON(ev *EventNode, status string) EventJSON {
+	parents := make([]string, 0, len(ev.Parents()))
+	for _, p := range ev.Parents() {
+		parents = append(parents, p.Hex())
+	}
+
+	e := EventJSON{
+		ID:           ev.ID().Hex(),
+		Creator:      ev.Creator(),
+		Epoch:        ev.Epoch(),
+		Seq:          ev.Seq(),
+		Frame:        ev.Frame(),
+		Lamport:      ev.Lamport(),
+		Parents:      parents,
+		CreationTime: uint64(ev.CreationTime()),
+		MedianTime:   uint64(ev.MedianTime()),
+		Root:         ev.IsRoot,
+		Status:       status,
+	}
+	if ev.TxCount >= 0 {
+		txs := ev.TxCount
+		e.TxCount = &txs
+	}
+	return e
+}
+
+// JSON exports the events of the graph data ordered by lamport time and creator
+func (gd *GraphData) JSON(name string) *DagJSON {
+	events := gd.Events()
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Lamport() != events[j].Lamport() {
+			return events[i].Lamport() < events[j].Lamport()
+		}
+		if events[i].Creator() != events[j].Creator() {
+			return events[i].Creator() < events[j].Creator()
+		}
+		return events[i].ID().Hex() < events[j].ID().Hex()
+	})
+
+	res := &DagJSON{
+		Version: DagJSONVersion,
+		Name:    name,
+		Events:  make([]EventJSON, 0, len(events)),
+	}
+	for _, ev := range events {
+		res.Events = append(res.Events, NewEventJSON(ev, gd.Status(ev.NodeName)))
+	}
+	return res
+}
@@ -0,0 +1,230 @@
+package types
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Fantom-foundation/go-opera/inter"
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/dag"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+)
+
+// testEvent is an event of the tests, only the base fields are implemented
+type testEvent struct {
+	*dag.BaseEvent
+	operaEvent
+}
+
+type operaEvent struct {
+	inter.EventI
+}
+
+func (operaEvent) CreationTime() inter.Timestamp { return 0 }
+func (operaEvent) MedianTime() inter.Timestamp   { return 0 }
+func (operaEvent) GasPowerUsed() uint64          { return 0 }
+
+// testEvents is count of the test events, it makes the IDs of the forked events differ
+var testEvents int
+
+// newTestEvent returns an event with the lamport time after its parents
+func newTestEvent(epoch idx.Epoch, creator idx.ValidatorID, seq idx.Event, frame idx.Frame, parents ...*EventNode) *EventNode {
+	e := &dag.MutableBaseEvent{}
+	e.SetEpoch(epoch)
+	e.SetCreator(creator)
+	e.SetSeq(seq)
+	e.SetFrame(frame)
+	lamport := idx.Lamport(1)
+	hh := make(hash.Events, len(parents))
+	for i, p := range parents {
+		hh[i] = p.ID()
+		if p.Lamport() >= lamport {
+			lamport = p.Lamport() + 1
+		}
+	}
+	e.SetParents(hh)
+	e.SetLamport(lamport)
+	testEvents++
+	var rID [24]byte
+	rID[0], rID[1], rID[2] = byte(creator), byte(seq), byte(testEvents)
+	return NewEventNode(&testEvent{BaseEvent: e.Build(rID)})
+}
+
+func buildNode(e *EventNode) *dot.Node {
+	n := dot.NewNode(e.NodeName)
+	n.Set("label", fmt.Sprintf("root %t", e.IsRoot))
+	return n
+}
+
+// takeView takes the events and the edges between them into a new view
+func takeView(d *Dag, events []*EventNode, edges [][2]*EventNode) *View {
+	v := d.View()
+	for _, e := range events {
+		v.Node(e, buildNode)
+	}
+	for _, e := range edges {
+		v.Edge(e[0], e[1], func() *dot.Edge {
+			return dot.NewEdge(v.Node(e[0], buildNode), v.Node(e[1], buildNode))
+		})
+	}
+	return v
+}
+
+func TestDagSnapshot(t *testing.T) {
+	d := NewDag()
+	a1 := newTestEvent(1, 1, 1, 1)
+	b1 := newTestEvent(1, 2, 1, 1)
+	a2 := newTestEvent(1, 1, 2, 1, a1, b1)
+
+	// the first snapshot has no changes
+	s := takeView(d, []*EventNode{a1, b1}, nil).Snapshot()
+	for _, n := range []*EventNode{a1, b1} {
+		if status := s.Status(n.ID()); status != "" {
+			t.Errorf("first snapshot: %s is %q", n.ID(), status)
+		}
+	}
+
+	// a new event with one of its edges shown
+	s = takeView(d, []*EventNode{a1, b1, a2}, [][2]*EventNode{{a2, a1}}).Snapshot()
+	if status := s.Status(a2.ID()); status != StatusNew {
+		t.Errorf("new event is %q", status)
+	}
+	if status := s.Status(a1.ID()); status != "" {
+		t.Errorf("old event is %q", status)
+	}
+	if status := s.EdgeStatus(a2.ID(), a1.ID()); status != StatusNew {
+		t.Errorf("new edge is %q", status)
+	}
+
+	// the root flips, an edge of old events is shown for the first time
+	a2.IsRoot = true
+	s = takeView(d, []*EventNode{a1, b1, a2}, [][2]*EventNode{{a2, a1}, {a2, b1}}).Snapshot()
+	if status := s.Status(a2.ID()); status != StatusNewRoot {
+		t.Errorf("new root is %q", status)
+	}
+	if status := s.EdgeStatus(a2.ID(), a1.ID()); status != "" {
+		t.Errorf("old edge is %q", status)
+	}
+	if status := s.EdgeStatus(a2.ID(), b1.ID()); status != StatusNew {
+		t.Errorf("edge shown first is %q", status)
+	}
+
+	a2.IsRoot = false
+	s = takeView(d, []*EventNode{a1, b1, a2}, nil).Snapshot()
+	if status := s.Status(a2.ID()); status != StatusOldRoot {
+		t.Errorf("old root is %q", status)
+	}
+}
+
+func TestDagView(t *testing.T) {
+	d := NewDag()
+	a1 := newTestEvent(1, 1, 1, 1)
+	a2 := newTestEvent(1, 1, 2, 1, a1)
+	built := 0
+	build := func(e *EventNode) *dot.Node {
+		built++
+		return buildNode(e)
+	}
+
+	// the objects are built once and the marks of a view are dropped by the next one
+	v := d.View()
+	n := v.Node(a2, build)
+	n.SetColor(dot.MustParseColor("red"))
+	e := v.Edge(a2, a1, func() *dot.Edge { return dot.NewEdge(n, v.Stub(a1, buildNode)) })
+	e.SetPenWidth(2)
+	if v.Node(a2, build) != n || n.Get("color") != "red" {
+		t.Errorf("node taken again by the view: %s", n)
+	}
+
+	v = d.View()
+	if v.Node(a2, build) != n || built != 1 || n.Get("color") != "" {
+		t.Errorf("reused node: built %d, %s", built, n)
+	}
+	if v.Edge(a2, a1, func() *dot.Edge { t.Error("edge is built again"); return nil }) != e || e.Get("penwidth") != "" {
+		t.Errorf("reused edge: %s", e)
+	}
+	if stub := v.Stub(a1, buildNode); stub != v.Stub(a1, buildNode) || stub == v.Node(a1, buildNode) {
+		t.Errorf("stub %s", stub)
+	}
+	if d.Event(a1.ID()) != a1 {
+		t.Error("events of the view are not added")
+	}
+
+	// the node is built again if the root status changed, the label may show it
+	a2.IsRoot = true
+	if v := d.View(); v.Node(a2, build) != n || built != 2 || n.Get("label") != "root true" {
+		t.Errorf("node of a new root: built %d, %s", built, n)
+	}
+
+	// the pruned events are built again
+	d.Prune(2)
+	if d.Event(a2.ID()) != nil {
+		t.Error("pruned event is known")
+	}
+	if v := d.View(); v.Node(a2, build) == n {
+		t.Error("node of a pruned event is reused")
+	}
+}
+
+func TestDagPrune(t *testing.T) {
+	d := NewDag()
+	a1 := newTestEvent(1, 1, 1, 1)
+	b1 := newTestEvent(1, 2, 1, 1, a1)
+	c1 := newTestEvent(2, 1, 1, 1)
+	takeView(d, []*EventNode{a1, b1, c1}, [][2]*EventNode{{b1, a1}}).Snapshot()
+
+	d.Prune(2)
+	s := takeView(d, []*EventNode{a1, b1, c1}, [][2]*EventNode{{b1, a1}}).Snapshot()
+	if status := s.Status(a1.ID()); status != StatusNew {
+		t.Errorf("pruned event is %q", status)
+	}
+	if status := s.EdgeStatus(b1.ID(), a1.ID()); status != StatusNew {
+		t.Errorf("pruned edge is %q", status)
+	}
+	if status := s.Status(c1.ID()); status != "" {
+		t.Errorf("kept event is %q", status)
+	}
+}
+
+func TestMarkChanges(t *testing.T) {
+	a1 := newTestEvent(1, 1, 1, 1)
+	a2 := newTestEvent(1, 1, 2, 1, a1)
+	b1 := newTestEvent(1, 2, 1, 2)
+	b1.IsRoot = true
+
+	d := NewDag()
+	takeView(d, []*EventNode{a1, b1}, nil).Snapshot()
+	b1.IsRoot = false
+
+	v := takeView(d, []*EventNode{a1, a2, b1}, [][2]*EventNode{{a2, a1}})
+	gd := &GraphData{}
+	nodes := make(map[hash.Event]*dot.Node)
+	for _, ev := range []*EventNode{a1, a2, b1} {
+		nodes[ev.ID()] = v.Node(ev, buildNode)
+		gd.AddEventNode(nodes[ev.ID()], ev)
+	}
+	e := v.Edge(a2, a1, nil)
+	gd.AddEdge(e)
+
+	red, green, gray := dot.MustParseColor("red"), dot.MustParseColor("green"), dot.MustParseColor("gray")
+	gd.MarkChanges(v.Snapshot(), red, 2.5, green, gray)
+
+	if n := nodes[a2.ID()]; n.Get("color") != "red" || n.Get("penwidth") != "2.5" || gd.Status(a2.NodeName) != StatusNew {
+		t.Errorf("new event is not marked: %s", n)
+	}
+	if n := nodes[b1.ID()]; n.Get("fillcolor") != "gray" || gd.Status(b1.NodeName) != StatusOldRoot {
+		t.Errorf("old root is not marked: %s", n)
+	}
+	if n := nodes[a1.ID()]; n.Get("color") != "" || gd.Status(a1.NodeName) != "" {
+		t.Errorf("unchanged event is marked: %s", n)
+	}
+	if e.Get("color") != "red" || gd.Status(a2.NodeName+"->"+a1.NodeName) != StatusNew {
+		t.Errorf("new edge is not marked: %s", e)
+	}
+	edges := gd.EventEdges()
+	if len(edges) != 1 || edges[0].Status != StatusNew {
+		t.Errorf("edges %v", edges)
+	}
+}
@@ -0,0 +1,61 @@
+package types
+
+import (
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+)
+
+// BlockAtropos is an Atropos event as reported by the node for a block
+type BlockAtropos struct {
+	Block   idx.Block
+	Atropos hash.Event
+}
+
+// Decision is a frame decided by an Atropos and the events it confirms
+type Decision struct {
+	Block     idx.Block
+	Frame     idx.Frame
+	Atropos   *EventNode
+	Confirmed []*EventNode
+}
+
+// DecideFrames maps Atropos events to the fetched nodes.
+// Every Atropos confirms those of its ancestors which are not confirmed
+// by an earlier Atropos, so atropoi must be sorted by block.
+// Atropoi which were not fetched are skipped.
+func DecideFrames(nodes map[hash.Event]*EventNode, atropoi []BlockAtropos) []*Decision {
+	decisions := make([]*Decision, 0, len(atropoi))
+	confirmed := make(map[hash.Event]bool)
+
+	for _, a := range atropoi {
+		atropos, ok := nodes[a.Atropos]
+		if !ok {
+			continue
+		}
+		d := &Decision{
+			Block:   a.Block,
+			Frame:   atropos.Frame(),
+			Atropos: atropos,
+		}
+
+		toVisit := hash.Events{a.Atropos}
+		for len(toVisit) > 0 {
+			h := toVisit[len(toVisit)-1]
+			toVisit = toVisit[:len(toVisit)-1]
+			if confirmed[h] {
+				continue
+			}
+			n, ok := nodes[h]
+			if !ok {
+				continue
+			}
+			confirmed[h] = true
+			d.Confirmed = append(d.Confirmed, n)
+			toVisit = append(toVisit, n.Parents()...)
+		}
+
+		decisions = append(decisions, d)
+	}
+
+	return decisions
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+)
+
+func TestDecideFrames(t *testing.T) {
+	a1 := newTestEvent(1, 1, 1, 1)
+	b1 := newTestEvent(1, 2, 1, 1)
+	a2 := newTestEvent(1, 1, 2, 2, a1, b1)
+	b2 := newTestEvent(1, 2, 2, 2, b1, a2)
+	missing := newTestEvent(1, 3, 1, 1)
+
+	nodes := make(map[hash.Event]*EventNode)
+	for _, n := range []*EventNode{a1, b1, a2, b2} {
+		nodes[n.ID()] = n
+	}
+	decisions := DecideFrames(nodes, []BlockAtropos{
+		{Block: 1, Atropos: a2.ID()},
+		{Block: 2, Atropos: missing.ID()},
+		{Block: 3, Atropos: b2.ID()},
+	})
+
+	// the Atropos which was not fetched is skipped,
+	// the later Atropos confirms only the events not confirmed yet
+	if len(decisions) != 2 {
+		t.Fatalf("%d decisions", len(decisions))
+	}
+	for i, want := range []struct {
+		block     uint64
+		atropos   *EventNode
+		confirmed []*EventNode
+	}{
+		{1, a2, []*EventNode{a2, a1, b1}},
+		{3, b2, []*EventNode{b2}},
+	} {
+		d := decisions[i]
+		if uint64(d.Block) != want.block || d.Atropos != want.atropos || d.Frame != want.atropos.Frame() {
+			t.Errorf("decision %d: block %d, atropos %s, frame %d", i, d.Block, d.Atropos.ID(), d.Frame)
+		}
+		confirmed := make(map[hash.Event]bool)
+		for _, n := range d.Confirmed {
+			confirmed[n.ID()] = true
+		}
+		if len(confirmed) != len(want.confirmed) || len(d.Confirmed) != len(want.confirmed) {
+			t.Errorf("decision %d confirms %d events", i, len(d.Confirmed))
+		}
+		for _, n := range want.confirmed {
+			if !confirmed[n.ID()] {
+				t.Errorf("decision %d does not confirm %s", i, n.ID())
+			}
+		}
+	}
+}
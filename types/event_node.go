@@ -2,25 +2,73 @@ package types
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/Fantom-foundation/go-opera/inter"
+	"github.com/Fantom-foundation/lachesis-base/hash"
 )
 
 // A node to query to event data from
 type EventNode struct {
 	inter.EventI
-	NodeName  string
+	NodeName  string // full event hash
 	NodeGroup string
+	Label     string
+	IsRoot    bool
+	TxCount   int // -1 if the payload is not fetched
 }
 
 func NewEventNode(ev inter.EventI) *EventNode {
-	return &EventNode{
+	n := &EventNode{
 		EventI:    ev,
-		NodeName:  fmt.Sprintf("%s\n%d-%d", ev.ID().String(), ev.Frame(), ev.Seq()),
+		NodeName:  ev.ID().Hex(),
 		NodeGroup: fmt.Sprintf("host-%d", ev.Creator()),
+		TxCount:   -1,
 	}
+	n.Label = defaultLabel.Label(n)
+	return n
+}
+
+// Comment returns the comment attribute of the event node, it keeps the frame for the diff
+func (n EventNode) Comment() string {
+	return fmt.Sprintf("frame %d, seq %d", n.Frame(), n.Seq())
 }
 
 func (n EventNode) GetId() string {
 	return fmt.Sprintf("%d", n.Creator())
 }
+
+// MarkRoots computes the IsRoot status of the nodes locally.
+// An event is a Lachesis root if it is the first event of its creator in
+// a frame, i.e. it has no self-parent or its self-parent has a lower frame.
+// Events whose self-parent was not fetched keep their status, new events are non-roots.
+func MarkRoots(nodes map[hash.Event]*EventNode) {
+	for _, n := range nodes {
+		sp := n.SelfParent()
+		if sp == nil {
+			n.IsRoot = true
+			continue
+		}
+		if parent, ok := nodes[*sp]; ok {
+			n.IsRoot = parent.Frame() < n.Frame()
+		}
+	}
+}
+
+// Tooltip returns the event details for the tooltip of the node
+func (n EventNode) Tooltip() string {
+	parents := make([]string, 0, len(n.Parents()))
+	for _, p := range n.Parents() {
+		parents = append(parents, p.Hex())
+	}
+	txs := "unknown"
+	if n.TxCount >= 0 {
+		txs = strconv.Itoa(n.TxCount)
+	}
+	gas := n.GasPowerLeft()
+
+	return fmt.Sprintf("id: %s\ncreator: %d, seq: %d, frame: %d, lamport: %d\nparents:\n  %s\ngas power left: short %d, long %d\ngas used: %d\ntxs: %s",
+		n.ID().Hex(), n.Creator(), n.Seq(), n.Frame(), n.Lamport(),
+		strings.Join(parents, "\n  "), gas.Gas[0], gas.Gas[1], n.GasPowerUsed(), txs)
+}
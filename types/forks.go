@@ -0,0 +1,128 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+)
+
+// Kinds of the creator misbehaviour
+const (
+	// ForkSameSeq is a pair of events of the creator with the same seq
+	ForkSameSeq = "same-seq"
+	// ForkSelfParent is an event whose self-parent is not the previous event of the creator
+	ForkSelfParent = "bad-self-parent"
+	// ForkParents is events of the creator used as parents by a single event,
+	// the creator of the parents is the cheater
+	ForkParents = "same-creator-parents"
+)
+
+// Misbehaviour is a fork (equivocation) found in the DAG
+type Misbehaviour struct {
+	Kind    string
+	Creator idx.ValidatorID // the cheater
+	Events  hash.Events
+}
+
+// DetectForks finds the forks among the fetched events. Only the events
+// present in nodes are checked, so forks outside of them are not found.
+// The result is sorted by creator.
+func DetectForks(nodes map[hash.Event]*EventNode) []Misbehaviour {
+	res := make([]Misbehaviour, 0)
+	// forked parents by their sorted IDs, they are reported once for all the events with them
+	forkedParents := make(map[string]hash.Events)
+
+	bySeq := make(map[idx.ValidatorID]map[idx.Event]hash.Events)
+	for h, n := range nodes {
+		seqs, ok := bySeq[n.Creator()]
+		if !ok {
+			seqs = make(map[idx.Event]hash.Events)
+			bySeq[n.Creator()] = seqs
+		}
+		seqs[n.Seq()] = append(seqs[n.Seq()], h)
+
+		if sp := n.SelfParent(); sp != nil {
+			if p, ok := nodes[*sp]; ok && (p.Creator() != n.Creator() || p.Seq()+1 != n.Seq()) {
+				res = append(res, Misbehaviour{ForkSelfParent, n.Creator(), hash.Events{h, *sp}})
+			}
+		} else if n.Seq() > 1 {
+			res = append(res, Misbehaviour{ForkSelfParent, n.Creator(), hash.Events{h}})
+		}
+
+		byCreator := make(map[idx.ValidatorID]hash.Events)
+		for _, parent := range n.Parents() {
+			if p, ok := nodes[parent]; ok {
+				byCreator[p.Creator()] = append(byCreator[p.Creator()], parent)
+			}
+		}
+		for _, parents := range byCreator {
+			if len(parents) > 1 {
+				sortEvents(parents)
+				forkedParents[eventsKey(parents)] = parents
+			}
+		}
+	}
+	for _, parents := range forkedParents {
+		res = append(res, Misbehaviour{ForkParents, nodes[parents[0]].Creator(), parents})
+	}
+
+	for creator, seqs := range bySeq {
+		for _, hh := range seqs {
+			if len(hh) > 1 {
+				res = append(res, Misbehaviour{ForkSameSeq, creator, hh})
+			}
+		}
+	}
+
+	for _, m := range res {
+		sortEvents(m.Events)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Creator != res[j].Creator {
+			return res[i].Creator < res[j].Creator
+		}
+		if res[i].Kind != res[j].Kind {
+			return res[i].Kind < res[j].Kind
+		}
+		return res[i].Events[0].String() < res[j].Events[0].String()
+	})
+	return res
+}
+
+// sortEvents sorts the events by their short IDs, then by the full ones
+func sortEvents(hh hash.Events) {
+	sort.Slice(hh, func(i, j int) bool {
+		if a, b := hh[i].String(), hh[j].String(); a != b {
+			return a < b
+		}
+		return hh[i].Hex() < hh[j].Hex()
+	})
+}
+
+// eventsKey is a map key of the sorted events
+func eventsKey(hh hash.Events) string {
+	var b strings.Builder
+	for _, h := range hh {
+		b.Write(h.Bytes())
+	}
+	return b.String()
+}
+
+// WriteForks writes the misbehaviour summary, a line per misbehaviour
+func WriteForks(w io.Writer, forks []Misbehaviour) error {
+	for _, m := range forks {
+		events := make([]string, 0, len(m.Events))
+		for _, h := range m.Events {
+			events = append(events, h.Hex())
+		}
+		_, err := fmt.Fprintf(w, "creator %d: %s: %s\n", m.Creator, m.Kind, strings.Join(events, " "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
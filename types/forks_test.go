@@ -0,0 +1,40 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+)
+
+func TestDetectForks(t *testing.T) {
+	a1 := newTestEvent(1, 1, 1, 1)
+	fork := newTestEvent(1, 1, 1, 1)
+	b1 := newTestEvent(1, 2, 1, 1, a1, fork)
+	c1 := newTestEvent(1, 3, 1, 1, fork, a1)
+
+	nodes := make(map[hash.Event]*EventNode)
+	for _, n := range []*EventNode{a1, fork, b1, c1} {
+		nodes[n.ID()] = n
+	}
+	forks := DetectForks(nodes)
+
+	// the forked pair is reported once for the same seq and once for both events with it
+	if len(forks) != 2 {
+		t.Fatalf("%d forks: %v", len(forks), forks)
+	}
+	for i, kind := range []string{ForkParents, ForkSameSeq} {
+		m := forks[i]
+		if m.Kind != kind || m.Creator != 1 || len(m.Events) != 2 {
+			t.Errorf("fork %d: %+v", i, m)
+			continue
+		}
+		if !(m.Events[0] == a1.ID() && m.Events[1] == fork.ID() || m.Events[0] == fork.ID() && m.Events[1] == a1.ID()) {
+			t.Errorf("fork %d: events %v", i, m.Events)
+		}
+	}
+
+	delete(nodes, fork.ID())
+	if forks = DetectForks(nodes); len(forks) != 0 {
+		t.Errorf("forks without the forked event: %v", forks)
+	}
+}
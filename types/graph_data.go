@@ -2,10 +2,20 @@ package types
 
 import "github.com/Fantom-foundation/dag2dot-tool/dot"
 
+// Change statuses of the graph elements relative to the previous graph
+const (
+	StatusNew     = "new"
+	StatusNewRoot = "new-root"
+	StatusOldRoot = "old-root"
+)
+
 // GraphData consists of nodes and edges
 type GraphData struct {
-	nodes map[string]*dot.Node
-	edges map[string]*dot.Edge
+	nodes  map[string]*dot.Node
+	edges  map[string]*dot.Edge
+	events map[string]*EventNode
+	status map[string]string
+	forks  []Misbehaviour
 }
 
 // Add a node
@@ -17,6 +27,72 @@ func (gd *GraphData) AddNode(n *dot.Node) {
 	gd.nodes[n.Name()] = n
 }
 
+// Add a node of the event
+func (gd *GraphData) AddEventNode(n *dot.Node, ev *EventNode) {
+	if gd.events == nil {
+		gd.events = make(map[string]*EventNode)
+	}
+
+	gd.AddNode(n)
+	gd.events[n.Name()] = ev
+}
+
+// Events returns the events of the graph
+func (gd *GraphData) Events() []*EventNode {
+	events := make([]*EventNode, 0, len(gd.events))
+	for _, ev := range gd.events {
+		events = append(events, ev)
+	}
+	return events
+}
+
+// EventEdge is an edge from an event to its parent
+type EventEdge struct {
+	Event  *EventNode
+	Parent *EventNode
+	Status string
+}
+
+// EventEdges returns the edges between the events of the graph
+func (gd *GraphData) EventEdges() []EventEdge {
+	edges := make([]EventEdge, 0, len(gd.edges))
+	for k, e := range gd.edges {
+		ev, ok := gd.events[e.Source().Name()]
+		if !ok {
+			continue
+		}
+		parent, ok := gd.events[e.Destination().Name()]
+		if !ok {
+			continue
+		}
+		edges = append(edges, EventEdge{ev, parent, gd.Status(k)})
+	}
+	return edges
+}
+
+// Status returns the change status of a node or an edge marked by MarkChanges, "" if unchanged
+func (gd *GraphData) Status(name string) string {
+	return gd.status[name]
+}
+
+func (gd *GraphData) setStatus(name, status string) {
+	if gd.status == nil {
+		gd.status = make(map[string]string)
+	}
+
+	gd.status[name] = status
+}
+
+// SetForks keeps the misbehaviours found in the events of the graph
+func (gd *GraphData) SetForks(forks []Misbehaviour) {
+	gd.forks = forks
+}
+
+// Forks returns the misbehaviours found in the events of the graph
+func (gd *GraphData) Forks() []Misbehaviour {
+	return gd.forks
+}
+
 // Add an edge
 func (gd *GraphData) AddEdge(e *dot.Edge) {
 	if gd.edges == nil {
@@ -27,34 +103,44 @@ func (gd *GraphData) AddEdge(e *dot.Edge) {
 	gd.edges[key] = e
 }
 
-// Mark the change in the graph data using new color
-func (gd *GraphData) MarkChanges(old *GraphData, newColor, newPenWidth, colorRoot, colorNewRoot, colorOldRoot string) {
-	if old == nil {
+// Mark the changes of the snapshot in the graph data using new color
+func (gd *GraphData) MarkChanges(s *Snapshot, newColor dot.Color, newPenWidth float64, colorNewRoot, colorOldRoot dot.Color) {
+	if s == nil {
 		return
 	}
 
-	for k, n := range gd.nodes {
-		oldNode, ok := old.nodes[k]
-		if !ok {
-			n.Set("color", newColor)
-			n.Set("penwidth", newPenWidth)
-		} else {
-			if n.Get("fillcolor") != oldNode.Get("fillcolor") {
-				n.Set("style", "filled")
-				if n.Get("fillcolor") == colorRoot {
-					n.Set("fillcolor", colorNewRoot)
-				} else {
-					n.Set("fillcolor", colorOldRoot)
-				}
-			}
+	for k, ev := range gd.events {
+		n := gd.nodes[k]
+		status := s.Status(ev.ID())
+		switch status {
+		case StatusNew:
+			n.SetColor(newColor)
+			n.SetPenWidth(newPenWidth)
+		case StatusNewRoot:
+			n.SetStyle(dot.StyleFilled)
+			n.SetFillColor(colorNewRoot)
+		case StatusOldRoot:
+			n.SetStyle(dot.StyleFilled)
+			n.SetFillColor(colorOldRoot)
+		default:
+			continue
 		}
+		gd.setStatus(k, status)
 	}
 
 	for k, e := range gd.edges {
-		_, ok := old.edges[k]
+		ev, ok := gd.events[e.Source().Name()]
+		if !ok {
+			continue
+		}
+		parent, ok := gd.events[e.Destination().Name()]
 		if !ok {
-			e.Set("color", newColor)
-			e.Set("penwidth", newPenWidth)
+			continue
+		}
+		if s.EdgeStatus(ev.ID(), parent.ID()) == StatusNew {
+			e.SetColor(newColor)
+			e.SetPenWidth(newPenWidth)
+			gd.setStatus(k, StatusNew)
 		}
 	}
 }
@@ -0,0 +1,135 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+)
+
+// DefaultLabel is the label template of the event nodes by default
+const DefaultLabel = "{{.ID}}\n{{.Frame}}-{{.Seq}}"
+
+// EventView is the event data available to the label templates
+type EventView struct {
+	ID           string // short event ID
+	Hash         string // full event hash
+	HashPrefix   string // 4 bytes of the hash after the epoch and lamport in hex
+	Epoch        uint32
+	Lamport      uint32
+	Frame        uint32
+	Seq          uint32
+	Creator      uint32
+	Parents      int
+	Root         bool
+	TxCount      int // -1 if the payload is not fetched
+	GasUsed      uint64
+	CreationTime time.Time
+	MedianTime   time.Time
+}
+
+// NewEventView returns the view of the event
+func NewEventView(n *EventNode) EventView {
+	hex := n.ID().Hex()
+	return EventView{
+		ID:           n.ID().String(),
+		Hash:         hex,
+		HashPrefix:   strings.TrimPrefix(hex, "0x")[16:24],
+		Epoch:        uint32(n.Epoch()),
+		Lamport:      uint32(n.Lamport()),
+		Frame:        uint32(n.Frame()),
+		Seq:          uint32(n.Seq()),
+		Creator:      uint32(n.Creator()),
+		Parents:      len(n.Parents()),
+		Root:         n.IsRoot,
+		TxCount:      n.TxCount,
+		GasUsed:      n.GasPowerUsed(),
+		CreationTime: n.CreationTime().Time(),
+		MedianTime:   n.MedianTime().Time(),
+	}
+}
+
+var defaultLabel = mustParseLabel(DefaultLabel)
+
+// LabelTemplate makes the labels of the event nodes
+type LabelTemplate struct {
+	t *template.Template
+}
+
+// ParseLabel parses a text/template over EventView.
+// The template is checked against an empty view, so unknown fields are reported here.
+func ParseLabel(text string) (*LabelTemplate, error) {
+	t, err := template.New("label").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.Execute(&bytes.Buffer{}, EventView{}); err != nil {
+		return nil, err
+	}
+	return &LabelTemplate{t}, nil
+}
+
+func mustParseLabel(text string) *LabelTemplate {
+	lt, err := ParseLabel(text)
+	if err != nil {
+		panic(err)
+	}
+	return lt
+}
+
+// Label returns the label of the event
+func (lt *LabelTemplate) Label(n *EventNode) string {
+	var buf bytes.Buffer
+	if err := lt.t.Execute(&buf, NewEventView(n)); err != nil {
+		return fmt.Sprintf("%s\n%s", n.ID().String(), err)
+	}
+	return buf.String()
+}
+
+// ParentPort returns the port of the cell of the i-th parent in the event table
+func ParentPort(i int) string {
+	return "p" + strconv.Itoa(i)
+}
+
+// EventTable returns the HTML label of the event: a table of its fields
+// and a cell per parent, the edges to the parents start from ParentPort.
+func EventTable(n *EventNode) string {
+	v := NewEventView(n)
+	field := func(name string, value interface{}) *dot.HTMLElement {
+		return dot.Row(
+			dot.Cell(dot.Text(name)).Align("LEFT"),
+			dot.Cell(dot.Text(fmt.Sprint(value))).Align("RIGHT"),
+		)
+	}
+	rows := []*dot.HTMLElement{
+		dot.Row(dot.Cell(dot.Bold(dot.Text(v.ID))).ColSpan(2)),
+		field("creator", v.Creator),
+		field("frame", v.Frame),
+		field("seq", v.Seq),
+		field("lamport", v.Lamport),
+	}
+	if v.TxCount >= 0 {
+		rows = append(rows, field("txs", v.TxCount))
+	}
+	if parents := n.Parents(); len(parents) > 0 {
+		cells := make([]*dot.HTMLElement, len(parents))
+		for i, h := range parents {
+			// the short ID is "epoch:lamport:hash", the hash part is enough to tell the parents apart
+			id := h.String()
+			cells[i] = dot.Cell(dot.Font(dot.Text(id[strings.LastIndex(id, ":")+1:])).PointSize(8)).Port(ParentPort(i))
+		}
+		inner := dot.Table(dot.Row(cells...)).Border(0).CellBorder(1).CellSpacing(0)
+		rows = append(rows, dot.Row(dot.Cell(inner).ColSpan(2).Attr("CELLPADDING", "0")))
+	}
+
+	label, err := dot.HTMLLabel(dot.Table(rows...).Border(0).CellBorder(1).CellSpacing(0))
+	if err != nil {
+		// the table is built of valid elements only
+		return n.Label
+	}
+	return label
+}
@@ -0,0 +1,26 @@
+package types
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLabel(t *testing.T) {
+	n := newTestEvent(1, 2, 3, 4)
+	if expected := fmt.Sprintf("%s\n4-3", n.ID().String()); n.Label != expected {
+		t.Errorf("default label '%s' != '%s'", n.Label, expected)
+	}
+
+	lt, err := ParseLabel("{{.Creator}}/{{.Seq}}{{if .Root}} root{{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.IsRoot = true
+	if label := lt.Label(n); label != "2/3 root" {
+		t.Errorf("label '%s'", label)
+	}
+
+	if _, err = ParseLabel("{{.Unknown}}"); err == nil {
+		t.Error("unknown field is not reported")
+	}
+}
@@ -0,0 +1,303 @@
+package types
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// GraphWriter writes graph data in an output format
+type GraphWriter interface {
+	// Ext returns the file extension of the format
+	Ext() string
+	// Write writes the events and edges of the graph data
+	Write(w io.Writer, name string, gd *GraphData) error
+}
+
+// GraphWriters are the writers by the format names
+var GraphWriters = map[string]GraphWriter{
+	"json":    JSONWriter{},
+	"graphml": GraphMLWriter{},
+	"gexf":    GEXFWriter{},
+}
+
+// JSONWriter writes DagJSON
+type JSONWriter struct{}
+
+func (JSONWriter) Ext() string {
+	return "json"
+}
+
+func (JSONWriter) Write(w io.Writer, name string, gd *GraphData) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(gd.JSON(name))
+}
+
+// eventAttr is a typed event attribute of the exports
+type eventAttr struct {
+	name  string
+	typ   string // GraphML type
+	value func(ev *EventNode, status string) (string, bool)
+}
+
+func uintAttr(name string, value func(ev *EventNode) uint64) eventAttr {
+	return eventAttr{name, "long", func(ev *EventNode, _ string) (string, bool) {
+		return strconv.FormatUint(value(ev), 10), true
+	}}
+}
+
+var eventAttrs = []eventAttr{
+	{"group", "string", func(ev *EventNode, _ string) (string, bool) {
+		return ev.NodeGroup, true
+	}},
+	uintAttr("creator", func(ev *EventNode) uint64 { return uint64(ev.Creator()) }),
+	uintAttr("epoch", func(ev *EventNode) uint64 { return uint64(ev.Epoch()) }),
+	uintAttr("seq", func(ev *EventNode) uint64 { return uint64(ev.Seq()) }),
+	uintAttr("frame", func(ev *EventNode) uint64 { return uint64(ev.Frame()) }),
+	uintAttr("lamport", func(ev *EventNode) uint64 { return uint64(ev.Lamport()) }),
+	uintAttr("creationTime", func(ev *EventNode) uint64 { return uint64(ev.CreationTime()) }),
+	uintAttr("medianTime", func(ev *EventNode) uint64 { return uint64(ev.MedianTime()) }),
+	{"txCount", "int", func(ev *EventNode, _ string) (string, bool) {
+		return strconv.Itoa(ev.TxCount), ev.TxCount >= 0
+	}},
+	{"root", "boolean", func(ev *EventNode, _ string) (string, bool) {
+		return strconv.FormatBool(ev.IsRoot), true
+	}},
+	{"status", "string", func(_ *EventNode, status string) (string, bool) {
+		return status, status != ""
+	}},
+}
+
+// sortedEvents returns the events ordered by creator and seq
+func sortedEvents(gd *GraphData) []*EventNode {
+	events := gd.Events()
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Creator() != events[j].Creator() {
+			return events[i].Creator() < events[j].Creator()
+		}
+		if events[i].Seq() != events[j].Seq() {
+			return events[i].Seq() < events[j].Seq()
+		}
+		return events[i].ID().Hex() < events[j].ID().Hex()
+	})
+	return events
+}
+
+// sortedEdges returns the edges ordered by event and parent IDs
+func sortedEdges(gd *GraphData) []EventEdge {
+	edges := gd.EventEdges()
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i].Event.ID().Hex(), edges[j].Event.ID().Hex()
+		if a != b {
+			return a < b
+		}
+		return edges[i].Parent.ID().Hex() < edges[j].Parent.ID().Hex()
+	})
+	return edges
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// GraphMLWriter writes GraphML, creators are nested graphs which yEd shows as groups
+type GraphMLWriter struct{}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID    string        `xml:"id,attr"`
+	Data  []graphMLData `xml:"data"`
+	Graph *graphMLGraph `xml:"graph,omitempty"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (GraphMLWriter) Ext() string {
+	return "graphml"
+}
+
+func (GraphMLWriter) Write(w io.Writer, name string, gd *GraphData) error {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphMLGraph{ID: name, EdgeDefault: "directed"},
+	}
+	for _, attr := range eventAttrs {
+		doc.Keys = append(doc.Keys, graphMLKey{attr.name, "node", attr.name, attr.typ})
+	}
+	doc.Keys = append(doc.Keys,
+		graphMLKey{"label", "node", "label", "string"},
+		graphMLKey{"edgeStatus", "edge", "status", "string"})
+
+	groups := make(map[string]*graphMLNode)
+	groupNames := make([]string, 0)
+	for _, ev := range sortedEvents(gd) {
+		group, ok := groups[ev.NodeGroup]
+		if !ok {
+			group = &graphMLNode{
+				ID:    ev.NodeGroup,
+				Data:  []graphMLData{{"label", ev.NodeGroup}},
+				Graph: &graphMLGraph{ID: ev.NodeGroup + ":", EdgeDefault: "directed"},
+			}
+			groups[ev.NodeGroup] = group
+			groupNames = append(groupNames, ev.NodeGroup)
+		}
+
+		n := graphMLNode{ID: ev.ID().Hex()}
+		n.Data = append(n.Data, graphMLData{"label", ev.Label})
+		status := gd.Status(ev.NodeName)
+		for _, attr := range eventAttrs {
+			if value, ok := attr.value(ev, status); ok {
+				n.Data = append(n.Data, graphMLData{attr.name, value})
+			}
+		}
+		group.Graph.Nodes = append(group.Graph.Nodes, n)
+	}
+	for _, name := range groupNames {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, *groups[name])
+	}
+
+	for _, e := range sortedEdges(gd) {
+		edge := graphMLEdge{Source: e.Event.ID().Hex(), Target: e.Parent.ID().Hex()}
+		if e.Status != "" {
+			edge.Data = append(edge.Data, graphMLData{"edgeStatus", e.Status})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+
+	return writeXML(w, doc)
+}
+
+// GEXFWriter writes GEXF 1.3, creators are in the "group" attribute to partition nodes in Gephi
+type GEXFWriter struct{}
+
+type gexf struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Xmlns   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID     string          `xml:"id,attr"`
+	Label  string          `xml:"label,attr"`
+	Values []gexfAttrValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     string          `xml:"id,attr"`
+	Source string          `xml:"source,attr"`
+	Target string          `xml:"target,attr"`
+	Values []gexfAttrValue `xml:"attvalues>attvalue,omitempty"`
+}
+
+type gexfAttrValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func (GEXFWriter) Ext() string {
+	return "gexf"
+}
+
+func (GEXFWriter) Write(w io.Writer, name string, gd *GraphData) error {
+	doc := gexf{
+		Xmlns:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Graph:   gexfGraph{DefaultEdgeType: "directed"},
+	}
+
+	nodeAttrs := gexfAttributes{Class: "node"}
+	for _, attr := range eventAttrs {
+		typ := attr.typ
+		if typ == "int" {
+			typ = "integer"
+		}
+		nodeAttrs.Attributes = append(nodeAttrs.Attributes, gexfAttribute{attr.name, attr.name, typ})
+	}
+	edgeAttrs := gexfAttributes{Class: "edge", Attributes: []gexfAttribute{{"status", "status", "string"}}}
+	doc.Graph.Attributes = []gexfAttributes{nodeAttrs, edgeAttrs}
+
+	for _, ev := range sortedEvents(gd) {
+		n := gexfNode{ID: ev.ID().Hex(), Label: ev.Label}
+		status := gd.Status(ev.NodeName)
+		for _, attr := range eventAttrs {
+			if value, ok := attr.value(ev, status); ok {
+				n.Values = append(n.Values, gexfAttrValue{attr.name, value})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+
+	for i, e := range sortedEdges(gd) {
+		edge := gexfEdge{
+			ID:     strconv.Itoa(i),
+			Source: e.Event.ID().Hex(),
+			Target: e.Parent.ID().Hex(),
+		}
+		if e.Status != "" {
+			edge.Values = append(edge.Values, gexfAttrValue{"status", e.Status})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+
+	return writeXML(w, doc)
+}
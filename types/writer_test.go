@@ -0,0 +1,85 @@
+package types
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Fantom-foundation/lachesis-base/hash"
+	"github.com/Fantom-foundation/lachesis-base/inter/dag"
+	"github.com/Fantom-foundation/lachesis-base/inter/idx"
+
+	"github.com/Fantom-foundation/dag2dot-tool/dot"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the writers")
+
+// fixedEvent returns an event with a fixed ID, unlike newTestEvent
+func fixedEvent(creator idx.ValidatorID, seq idx.Event, lamport idx.Lamport, parents ...*EventNode) *EventNode {
+	e := &dag.MutableBaseEvent{}
+	e.SetEpoch(1)
+	e.SetCreator(creator)
+	e.SetSeq(seq)
+	e.SetFrame(1)
+	e.SetLamport(lamport)
+	hh := make(hash.Events, len(parents))
+	for i, p := range parents {
+		hh[i] = p.ID()
+	}
+	e.SetParents(hh)
+	n := NewEventNode(&testEvent{BaseEvent: e.Build([24]byte{byte(creator), byte(seq)})})
+	n.Label = n.ID().String()
+	return n
+}
+
+// writerTestData returns the graph data of the second snapshot of a small DAG:
+// a new event with new edges, a new root and an unchanged event with transactions
+func writerTestData() *GraphData {
+	a := fixedEvent(1, 1, 1)
+	a.IsRoot = true
+	a.TxCount = 2
+	b := fixedEvent(2, 1, 1)
+	c := fixedEvent(1, 2, 2, a, b)
+
+	d := NewDag()
+	takeView(d, []*EventNode{a, b}, nil).Snapshot()
+	b.IsRoot = true
+	v := takeView(d, []*EventNode{a, b, c}, [][2]*EventNode{{c, a}, {c, b}})
+
+	gd := &GraphData{}
+	for _, e := range []*EventNode{a, b, c} {
+		gd.AddEventNode(v.Node(e, buildNode), e)
+	}
+	for _, p := range []*EventNode{a, b} {
+		gd.AddEdge(dot.NewEdge(v.Node(c, buildNode), v.Node(p, buildNode)))
+	}
+	gd.MarkChanges(v.Snapshot(), dot.MustParseColor("red"), 2, dot.MustParseColor("yellow"), dot.MustParseColor("gray"))
+	return gd
+}
+
+// TestGraphWriters compares the outputs with the golden files, go test -update rewrites them
+func TestGraphWriters(t *testing.T) {
+	for _, format := range []string{"json", "graphml", "gexf"} {
+		w := GraphWriters[format]
+		var buf bytes.Buffer
+		if err := w.Write(&buf, "DAG-EPOCH-1", writerTestData()); err != nil {
+			t.Fatal(err)
+		}
+
+		golden := filepath.Join("testdata", "graph."+w.Ext())
+		if *update {
+			if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("%s:\n%s\n!=\n%s", format, buf.Bytes(), expected)
+		}
+	}
+}